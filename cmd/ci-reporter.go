@@ -17,9 +17,13 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"fmt"
-	"strings"
-	"sync"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	ci_reporter "github.com/leonardpahlke/ci-signal-report/pkg/ci-reporter"
 )
@@ -28,23 +32,75 @@ func main() {
 	meta := ci_reporter.SetMeta()
 	cireporters := meta.GetReporters()
 
+	// Ctrl-C stops all requests, the data gathered so far is printed (a second Ctrl-C exits immediately)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if meta.Command == ci_reporter.ServeCommand {
+		if err := ci_reporter.NewServer(meta).Run(ctx); err != nil {
+			log.Fatalf("Error serving the report.\n[ERROR] %v", err)
+		}
+		return
+	}
+
+	if meta.Flags.Watch > 0 {
+		if err := ci_reporter.Watch(ctx, meta); err != nil {
+			log.Fatalf("Error watching the report.\n[ERROR] %v", err)
+		}
+		return
+	}
+
 	// request report data
-	report := ci_reporter.Report{}
-	var wg sync.WaitGroup
-	for _, r := range cireporters {
-		wg.Add(1)
-		report = append(report, r.RequestData(meta, &wg))
+	report := ci_reporter.RequestReport(ctx, meta, cireporters)
+	generatedAt := time.Now()
+	for _, reportData := range report {
+		if !reportData.Complete() {
+			fmt.Fprintf(os.Stderr, "The %s report is incomplete, see the errors in the report\n", reportData.Name)
+		}
 	}
-	wg.Wait()
 
 	// print report data
-	if meta.Flags.JSONOut {
-		report.PrintJSON()
+	if meta.Command == ci_reporter.WeeklyCommand {
+		if err := ci_reporter.WriteWeeklySummary(os.Stdout, meta, report, generatedAt); err != nil {
+			log.Fatalf("Error writing the weekly summary.\n[ERROR] %v", err)
+		}
+	} else if meta.Flags.SMTPAddr != "" {
+		message, err := ci_reporter.BuildEmail(meta, report, generatedAt)
+		if err != nil {
+			log.Fatalf("Error building the email.\n[ERROR] %v", err)
+		}
+		if err := ci_reporter.SendEmail(ctx, meta, message); err != nil {
+			log.Fatalf("Error sending the email over %s.\n[ERROR] %v", meta.Flags.SMTPAddr, err)
+		}
 	} else {
-		for _, r := range cireporters {
-			reportData := r.GetData()
-			fmt.Printf("\n%s REPORT\n", strings.ToUpper(reportData.Name))
-			r.Print(meta, reportData)
+		if err := ci_reporter.PrintReport(meta, cireporters, report); err != nil {
+			log.Fatalf("Error printing the report.\n[ERROR] %v", err)
 		}
 	}
+
+	if meta.Flags.MetricsFile != "" {
+		stats := meta.Fetcher.Stats()
+		if err := ci_reporter.WriteMetricsFile(meta.Flags.MetricsFile, report, generatedAt, &stats); err != nil {
+			log.Fatalf("Error writing metrics file.\n[ERROR] %v", err)
+		}
+	}
+
+	if meta.Flags.Stats {
+		stats := meta.Fetcher.Stats()
+		fmt.Fprintf(os.Stderr, "\n%d requests (%d retries, %d failed), %d bytes received, %d served from cache, %d not modified\n", stats.Requests, stats.Retries, stats.Failures, stats.Bytes, stats.CacheHits, stats.NotModified)
+	}
+
+	// scripts can react on the health of CI via the exit code
+	code, err := ci_reporter.ExitCode(meta, report)
+	if err != nil {
+		log.Fatalf("Error evaluating -fail-on %q.\n[ERROR] %v", meta.Flags.FailOn, err)
+	}
+	if code != ci_reporter.ExitHealthy {
+		fmt.Fprintf(os.Stderr, "Exit code %d: %s\n", code, ci_reporter.ExitCodeDescription(code))
+		os.Exit(code)
+	}
 }
@@ -0,0 +1,118 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// httpCache stores responses of GET requests on disk, entries are keyed by url and credentials, so a response is
+// never served to a request with another token
+type httpCache struct {
+	dir string
+}
+
+// httpCacheEntry a cached response, ETag and Last-Modified are used to send conditional requests
+type httpCacheEntry struct {
+	URL string `json:"url"`
+	// AuthHash hash of the Authorization header of the request ('' without credentials)
+	AuthHash     string    `json:"auth_hash,omitempty"`
+	ETag         string    `json:"etag"`
+	LastModified string    `json:"last_modified"`
+	StoredAt     time.Time `json:"stored_at"`
+	Body         []byte    `json:"body"`
+}
+
+// defaultCacheDir returns the directory the cache is stored in if -cache-dir is not set
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "ci-signal-report")
+	}
+	return filepath.Join(dir, "ci-signal-report")
+}
+
+// cacheAuthHash returns the hash of the credentials of a request, the credentials themselves are not stored
+func cacheAuthHash(header http.Header) string {
+	auth := header.Get("Authorization")
+	if auth == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(auth))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *httpCache) path(url string, authHash string) string {
+	sum := sha256.Sum256([]byte(url + "\n" + authHash))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// get returns the cached entry of an url requested with the credentials of authHash, a missing or unreadable entry
+// is treated as cache miss
+func (c *httpCache) get(url string, authHash string) (*httpCacheEntry, bool) {
+	data, err := ioutil.ReadFile(c.path(url, authHash))
+	if err != nil {
+		return nil, false
+	}
+	var entry httpCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.URL != url || entry.AuthHash != authHash {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// put stores an entry, the file is replaced atomically so concurrent runs never read a partial entry
+func (c *httpCache) put(entry httpCacheEntry) error {
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(c.dir, "entry-*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(entry.URL, entry.AuthHash))
+}
+
+// fresh checks if an entry can be served without asking the upstream
+func (e *httpCacheEntry) fresh(maxAge time.Duration) bool {
+	return maxAge > 0 && time.Since(e.StoredAt) < maxAge
+}
+
+// response returns the cached body as successful response
+func (e *httpCacheEntry) response() *FetchResponse {
+	return &FetchResponse{StatusCode: http.StatusOK, Status: "200 OK (cached)", Header: http.Header{}, Body: e.Body}
+}
@@ -21,9 +21,12 @@ import (
 	"flag"
 	"fmt"
 	"log"
-	"regexp"
+	"os"
+	"os/signal"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/google/go-github/v34/github"
 	"github.com/kelseyhightower/envconfig"
@@ -33,6 +36,9 @@ import (
 // Environment variables that can be set using the ci-reporter
 type metaEnv struct {
 	GithubToken string `envconfig:"GITHUB_AUTH_TOKEN" required:"true"`
+	// SMTPUsername & SMTPPassword credentials of the smtp server set via -smtp (optional)
+	SMTPUsername string `envconfig:"SMTP_USERNAME"`
+	SMTPPassword string `envconfig:"SMTP_PASSWORD"`
 }
 
 // Flags that can be set using the ci-reporter
@@ -45,15 +51,90 @@ type metaFlags struct {
 	ReleaseVersion []string
 	// JSONOut specifies if the output should be in json format
 	JSONOut bool
-	// Specify a report (if this is specified only one report will be printed e.g. SpecificReport: 'github' -> github report)
-	SpecificReport string
+	// Reports names of the reporters that are run (e.g. ['github', 'testgrid'])
+	Reports []string
+	// StaleAfter overrides the expected cadence of all testgrid dashboards (0 uses the dashboard defaults)
+	StaleAfter time.Duration
+	// StaleSeverity severity that is assigned to jobs that have not been run within their expected cadence
+	StaleSeverity Severity
+	// SortBy defines the order of report records, options: 'severity', 'age', 'sig', 'name'
+	SortBy string
+	// SigFilter normalized sig names, only records of these sigs are reported (e.g. ["storage"])
+	SigFilter []string
+	// GroupBy groups the report, options: '' (not grouped), 'sig'
+	GroupBy string
+	// Timeout limits the time to request all report data (0 means no limit)
+	Timeout time.Duration
+	// RequestTimeout limits the time of a single http request (0 means no limit)
+	RequestTimeout time.Duration
+	// Stats prints request metrics after the report
+	Stats bool
+	// ServeAddr address the report is served on (serve command)
+	ServeAddr string
+	// ServeInterval interval the served report is refreshed in (serve command)
+	ServeInterval time.Duration
+	// MetricsFile path the report is written to in the prometheus text format ('' disables it)
+	MetricsFile string
+	// Watch interval the report is re-requested in, only changes are printed (0 disables watching)
+	Watch time.Duration
+	// OnChangeExec shell command that is run on changes in watch mode, it receives the changes as json via stdin
+	OnChangeExec string
+	// OnChangeWebhook url the changes are posted to as json in watch mode
+	OnChangeWebhook string
+	// Template path of a template file the report (or the weekly summary) is rendered with ('' disables it)
+	Template string
+	// Snapshot path of the report the weekly summary is compared to
+	Snapshot string
+	// UpdateSnapshot tells if the weekly command replaces the snapshot with the current report
+	UpdateSnapshot bool
+	// Format output format, options: 'text', 'json', 'email', 'csv', 'tsv', 'junit'
+	Format string
+	// SMTPAddr smtp server (host:port) the email is sent over ('' prints the email)
+	SMTPAddr string
+	// EmailFrom sender of the email
+	EmailFrom string
+	// EmailTo recipients of the email
+	EmailTo []string
+	// JobsFile & IssuesFile files the testgrid jobs and github issues are written to with -format csv/tsv
+	JobsFile   string
+	IssuesFile string
+	// FailOn condition on the report that sets the exit code to ExitFailOn if it is true (nil disables it)
+	FailOn *FailOnExpression
+	// ExitThreshold which state of the report results in a non zero exit code, options: 'none', 'partial',
+	// 'failing', 'flaky'
+	ExitThreshold string
+	// AttentionDays issues without human activity for this number of days need attention
+	AttentionDays int
+	// FilterRules decide which github issues are part of the report
+	FilterRules GithubFilterRules
+	// Repos repositories ('owner/repo') the github issues are requested from
+	Repos []string
+	// Search qualifiers of the github search api the github issues are requested with (like 'org:kubernetes')
+	Search string
+	// GithubAPI api the github issues are requested with, options: 'rest', 'graphql'
+	GithubAPI string
 }
 
+// Commands that can be passed as first argument (e.g. 'ci-reporter serve -addr :8080')
+const (
+	// reportCommand prints the report once (default)
+	reportCommand = ""
+	// ServeCommand serves the report over http and refreshes it periodically
+	ServeCommand = "serve"
+	// WeeklyCommand prints the weekly summary of the report and its changes since the last snapshot
+	WeeklyCommand = "weekly"
+)
+
+var commands = []string{ServeCommand, WeeklyCommand}
+
 // Meta meta struct to use ci-reporter functions
 type Meta struct {
-	Env                metaEnv
-	Flags              metaFlags
+	Env   metaEnv
+	Flags metaFlags
+	// Command the command given as first argument, options: '' (print the report), 'serve', 'weekly'
+	Command            string
 	GitHubClient       *github.Client
+	Fetcher            *Fetcher
 	DataPostProcessing func(CIReport, string, chan ReportDataField, *sync.WaitGroup) ReportData
 }
 
@@ -80,18 +161,229 @@ func SetMeta() Meta {
 	isFlagEmojiOff := flag.Bool("emoji-off", false, "Remove emojis from report print-out")
 
 	// -v default: ""
-	releaseVersion := flag.String("v", "", "Adds specific K8s release version to the report (like -v '1.22, 1.21' or -v 1.22)")
+	releaseVersion := flag.String("v", "", "Adds specific K8s release version to the report (like -v '1.22, 1.21' or -v 1.22), -v auto detects the supported release versions")
 
 	// -emoji-off - default : off
 	isJSONOut := flag.Bool("json", false, "Report gets printed out in json format")
 
-	// -emoji-off - default : off
-	specificReport := flag.String("report", "", fmt.Sprintf("Specify report, options: '%s', '%s'", githubReport, testgridReport))
+	// -report default: "" (all reports)
+	specificReport := flag.String("report", "", fmt.Sprintf("Specify reports as comma separated list (like -report github,testgrid) or exclude reports (like -report=-github), options: %s", reporterUsage()))
+
+	// -stale-after default: 0 (dashboard defaults)
+	staleAfter := flag.Duration("stale-after", 0, "Jobs that have not been run for this duration are reported as stale (like -stale-after 24h), defaults depend on the dashboard")
+
+	// -stale-severity default: 2
+	staleSeverity := flag.Int("stale-severity", int(MediumSeverity), fmt.Sprintf("Severity of stale jobs, options: %d (light), %d (medium), %d (high)", LightSeverity, MediumSeverity, HighSeverity))
+
+	// -sort default: severity
+	sortBy := flag.String("sort", sortBySeverity, fmt.Sprintf("Sort report records, options: '%s'", strings.Join(sortOptions, "', '")))
+
+	// -sig default: "" (all sigs)
+	sigFilter := flag.String("sig", "", "Only report records of specific sigs (like -sig storage or -sig 'sig/storage, node')")
+
+	// -group-by default: "" (not grouped)
+	groupBy := flag.String("group-by", "", fmt.Sprintf("Group the report, options: '%s'", groupBySig))
+
+	// -timeout default: 5m
+	timeout := flag.Duration("timeout", 5*time.Minute, "Maximum duration to request the report data, a partial report is printed if it is exceeded (0 disables the timeout)")
+
+	// -request-timeout default: 30s
+	requestTimeout := flag.Duration("request-timeout", 30*time.Second, "Maximum duration of a single http request (0 disables the timeout)")
+
+	// -concurrency default: 8
+	concurrency := flag.Int("concurrency", 8, "Maximum number of http requests in flight")
+
+	// -retries default: 3
+	retries := flag.Int("retries", 3, "Number of retries of http requests that failed with a network error, a 5xx response or an exceeded rate limit")
+
+	// -stats default: off
+	isStatsOn := flag.Bool("stats", false, "Prints the number of http requests and received bytes after the report")
+
+	// -cache-dir default: "" (no cache)
+	cacheDir := flag.String("cache-dir", "", fmt.Sprintf("Directory http responses are cached in, cached responses are revalidated with conditional requests (like -cache-dir %s)", defaultCacheDir()))
+
+	// -no-cache default: off
+	isNoCache := flag.Bool("no-cache", false, "Bypasses the http cache")
+
+	// -max-age default: 0 (always revalidate)
+	maxAge := flag.Duration("max-age", 0, "Cached responses younger than this are used without asking github or testgrid (like -max-age 5m)")
+
+	// -addr default: :8080
+	serveAddr := flag.String("addr", ":8080", "Address the report is served on (serve)")
+
+	// -interval default: 10m
+	serveInterval := flag.Duration("interval", 10*time.Minute, "Interval the served report is refreshed in (serve)")
+
+	// -metrics-file default: "" (off)
+	metricsFile := flag.String("metrics-file", "", "Writes the report as prometheus metrics to this file (like -metrics-file /var/lib/node_exporter/ci-signal.prom)")
+
+	// -watch default: 0 (off)
+	watch := flag.Duration("watch", 0, "Re-runs the report in this interval and prints only the changes (like -watch 5m)")
+
+	// -on-change-exec default: "" (off)
+	onChangeExec := flag.String("on-change-exec", "", "Shell command that is run on changes in watch mode, the changes are passed as json via stdin")
+
+	// -on-change-webhook default: "" (off)
+	onChangeWebhook := flag.String("on-change-webhook", "", "URL the changes are posted to as json in watch mode")
+
+	// -template default: "" (off, the weekly command uses its default template)
+	templateFile := flag.String("template", "", "Go template file the report is rendered with, files named like *.html* are rendered with html/template (weekly: renders the weekly summary)")
+
+	// -snapshot default: weekly-snapshot.json in the user cache directory
+	snapshot := flag.String("snapshot", defaultWeeklySnapshot(), "File the report is stored in to compare the next weekly summary to (weekly)")
+
+	// -update-snapshot default: on
+	updateSnapshot := flag.Bool("update-snapshot", true, "Replaces the snapshot with the current report, disable it to draft the weekly summary multiple times (weekly)")
+
+	// -format default: text
+	format := flag.String("format", formatText, fmt.Sprintf("Output format, options: '%s'", strings.Join(formatOptions, "', '")))
+
+	// -smtp default: "" (the email is printed)
+	smtpAddr := flag.String("smtp", "", "SMTP server (host:port) the report is sent over with -format email, credentials are read from SMTP_USERNAME and SMTP_PASSWORD")
+
+	// -email-from default: ci-signal-report@localhost
+	emailFrom := flag.String("email-from", "", fmt.Sprintf("Sender of the email (default %s)", defaultEmailFrom))
+
+	// -email-to default: "" (no recipients)
+	emailTo := flag.String("email-to", "", "Comma separated recipients of the email (like -email-to \"a@example.com, b@example.com\")")
+
+	// -jobs-file default: "" (printed)
+	jobsFile := flag.String("jobs-file", "", "File the testgrid jobs are written to with -format csv or tsv")
+
+	// -issues-file default: "" (printed)
+	issuesFile := flag.String("issues-file", "", "File the github issues are written to with -format csv or tsv")
+
+	// -fail-on default: "" (off)
+	failOn := flag.String("fail-on", "", fmt.Sprintf("Exits with code %d if the condition on the report is true (like -fail-on \"blocking.failing>0 || severity>=high\")", ExitFailOn))
+
+	// -exit-threshold default: none
+	exitThreshold := flag.String("exit-threshold", exitThresholdNone, fmt.Sprintf("State of the report that results in a non zero exit code, options: '%s' (see the README for the exit codes)", strings.Join(exitThresholdOptions, "', '")))
+
+	// -attention-days default: 14
+	attentionDays := flag.Int("attention-days", defaultAttentionDays, "Issues without update for this number of days (or with a bot comment nobody reacted to for this number of days) are listed as needing attention")
+
+	// -filter-rules default: "" (backlog, triage accepted, lifecycle stale or rotten issues are filtered out)
+	filterRulesFile := flag.String("filter-rules", "", "JSON file with the rules which github issues are part of the report (see the README)")
+
+	// -repos default: kubernetes/kubernetes
+	repos := flag.String("repos", defaultGithubRepo, "Comma separated repositories the github issues are requested from (like -repos \"kubernetes/kubernetes, kubernetes/test-infra\"), empty to request the -search only")
+
+	// -search default: "" (off)
+	search := flag.String("search", "", "Qualifiers of the github search api the github issues are requested with in addition to -repos (like -search \"org:kubernetes org:kubernetes-sigs\")")
+
+	// -github-api default: rest
+	githubAPI := flag.String("github-api", githubAPIREST, fmt.Sprintf("API the github issues are requested with, options: '%s' (graphql needs a few requests instead of three per issue)", strings.Join(githubAPIOptions, "', '")))
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [command] [flags]\n\nCommands:\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %-8s prints the report (default)\n", "")
+		fmt.Fprintf(flag.CommandLine.Output(), "  %-8s serves the report over http and refreshes it periodically\n", ServeCommand)
+		fmt.Fprintf(flag.CommandLine.Output(), "  %-8s prints the weekly summary and the changes since the last snapshot\n\nFlags:\n", WeeklyCommand)
+		flag.PrintDefaults()
+	}
 
-	flag.Parse()
+	// The command has to be given before the flags
+	args := os.Args[1:]
+	command := reportCommand
+	if len(args) > 0 && containsString(commands, args[0]) {
+		command = args[0]
+		args = args[1:]
+	}
+	// flag.CommandLine exits on parse errors
+	_ = flag.CommandLine.Parse(args)
+	if flag.NArg() > 0 {
+		fmt.Fprintf(flag.CommandLine.Output(), "Unknown command %q\n", flag.Arg(0))
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+	if *watch < 0 {
+		usageFatalf("Information given via flag -watch must not be negative")
+	}
+	// the changes are detected on the jobs that -short leaves out
+	if *watch > 0 && *isFlagShortSet {
+		usageFatalf("Information given via flag -watch can not be combined with -short")
+	}
+	if command == ServeCommand && *serveInterval <= 0 {
+		usageFatalf("Information given via flag -interval must be greater than 0")
+	}
+
+	if Severity(*staleSeverity) < LightSeverity || Severity(*staleSeverity) > HighSeverity {
+		usageFatalf("Information given via flag -stale-severity does not match options [%d, %d, %d]", LightSeverity, MediumSeverity, HighSeverity)
+	}
+	reports, err := selectReporters(*specificReport)
+	if err != nil {
+		fmt.Fprintf(flag.CommandLine.Output(), "Information given via flag -report is invalid: %v\n", err)
+		flag.Usage()
+		os.Exit(ExitUsage)
+	}
+	if *groupBy != "" && *groupBy != groupBySig {
+		usageFatalf("Information given via flag -group-by does not match options [%s]", groupBySig)
+	}
+	if !containsString(formatOptions, *format) {
+		usageFatalf("Information given via flag -format does not match options [%s]", strings.Join(formatOptions, ", "))
+	}
+	// -json is kept as shorthand of -format json
+	if *isJSONOut && *format == formatText {
+		*format = formatJSON
+	}
+	*isJSONOut = *format == formatJSON
+	emailRecipients := []string{}
+	for _, to := range strings.Split(*emailTo, ",") {
+		if to = strings.TrimSpace(to); to != "" {
+			emailRecipients = append(emailRecipients, to)
+		}
+	}
+	if *smtpAddr != "" && (*format != formatEmail || len(emailRecipients) == 0) {
+		usageFatalf("Information given via flag -smtp requires -format %s and -email-to", formatEmail)
+	}
+	if !containsString(exitThresholdOptions, *exitThreshold) {
+		usageFatalf("Information given via flag -exit-threshold does not match options [%s]", strings.Join(exitThresholdOptions, ", "))
+	}
+	if *attentionDays <= 0 {
+		usageFatalf("Information given via flag -attention-days must be greater than 0")
+	}
+	if *maxAge > 0 && *cacheDir == "" {
+		usageFatalf("Information given via flag -max-age requires -cache-dir")
+	}
+	var failOnExpression *FailOnExpression
+	if *failOn != "" {
+		failOnExpression, err = ParseFailOn(*failOn)
+		if err != nil {
+			usageFatalf("Information given via flag -fail-on is invalid: %v", err)
+		}
+	}
+	githubRepos := []string{}
+	for _, repo := range strings.Split(*repos, ",") {
+		if repo = strings.TrimSpace(repo); repo == "" {
+			continue
+		}
+		if ownerRepo := strings.Split(repo, "/"); len(ownerRepo) != 2 || ownerRepo[0] == "" || ownerRepo[1] == "" {
+			usageFatalf("Information given via flag -repos is invalid: '%s' is not like 'owner/repo'", repo)
+		}
+		githubRepos = append(githubRepos, repo)
+	}
+	if len(githubRepos) == 0 && strings.TrimSpace(*search) == "" {
+		usageFatalf("Information given via flag -repos is empty and -search is not set")
+	}
+	if !containsString(githubAPIOptions, *githubAPI) {
+		usageFatalf("Information given via flag -github-api does not match options [%s]", strings.Join(githubAPIOptions, ", "))
+	}
+	filterRules, err := LoadGithubFilterRules(*filterRulesFile)
+	if err != nil {
+		usageFatalf("Information given via flag -filter-rules is invalid: %v", err)
+	}
+	if !containsString(sortOptions, *sortBy) {
+		usageFatalf("Information given via flag -sort does not match options [%s]", strings.Join(sortOptions, ", "))
+	}
+	// a broken template is reported before the report data is requested
+	if *templateFile != "" {
+		if _, err := parseTemplateFile(*templateFile); err != nil {
+			usageFatalf("Information given via flag -template is invalid: %v", err)
+		}
+	}
 
 	var env metaEnv
-	err := envconfig.Process("", &env)
+	err = envconfig.Process("", &env)
 	if err != nil {
 		// "Make sure to provide a GITHUB_AUTH_TOKEN, received an error during env decoding"
 		log.Fatalf("Error processing flags.\n[ERROR] %v", err)
@@ -105,51 +397,101 @@ func SetMeta() Meta {
 	tc := oauth2.NewClient(ctx, ts)
 	ghClient := github.NewClient(tc)
 
+	// Setup the http layer that is shared by all reports
+	fetcherOpts := FetcherOptions{
+		Concurrency:    *concurrency,
+		Retries:        *retries,
+		RequestTimeout: *requestTimeout,
+		CacheDir:       *cacheDir,
+		MaxAge:         *maxAge,
+	}
+	if *isNoCache {
+		fetcherOpts.CacheDir = ""
+	}
+	fetcher := NewFetcher(fetcherOpts)
+
+	// Release versions are either set explicitly or detected
+	var releaseVersions []string
+	if strings.TrimSpace(*releaseVersion) == releaseVersionAuto {
+		// Ctrl-C and -timeout stop the detection like they stop requesting the report
+		detectCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if *timeout > 0 {
+			var cancel context.CancelFunc
+			detectCtx, cancel = context.WithTimeout(detectCtx, *timeout)
+			defer cancel()
+		}
+		releaseVersions, err = detectReleaseVersions(detectCtx, fetcher, env.GithubToken)
+		if err != nil {
+			log.Fatalf("Error processing flag -v.\n[ERROR] %v", err)
+		}
+	} else {
+		releaseVersions, err = splitReleaseVersionInput(*releaseVersion)
+		if err != nil {
+			usageFatalf("Information given via flag -v is invalid: %v", err)
+		}
+	}
+
 	// Set meta data
 	return Meta{
 		Env: env,
 		Flags: metaFlags{
-			ShortOn:        *isFlagShortSet,
-			EmojisOff:      *isFlagEmojiOff,
-			ReleaseVersion: splitReleaseVersionInput(*releaseVersion),
-			JSONOut:        *isJSONOut,
-			SpecificReport: *specificReport,
+			ShortOn:         *isFlagShortSet,
+			EmojisOff:       *isFlagEmojiOff,
+			ReleaseVersion:  releaseVersions,
+			JSONOut:         *isJSONOut,
+			Reports:         reports,
+			StaleAfter:      *staleAfter,
+			StaleSeverity:   Severity(*staleSeverity),
+			SortBy:          *sortBy,
+			SigFilter:       splitSigInput(*sigFilter),
+			GroupBy:         *groupBy,
+			Timeout:         *timeout,
+			RequestTimeout:  *requestTimeout,
+			Stats:           *isStatsOn,
+			ServeAddr:       *serveAddr,
+			ServeInterval:   *serveInterval,
+			MetricsFile:     *metricsFile,
+			Watch:           *watch,
+			OnChangeExec:    *onChangeExec,
+			OnChangeWebhook: *onChangeWebhook,
+			Template:        *templateFile,
+			Snapshot:        *snapshot,
+			UpdateSnapshot:  *updateSnapshot,
+			Format:          *format,
+			SMTPAddr:        *smtpAddr,
+			EmailFrom:       *emailFrom,
+			EmailTo:         emailRecipients,
+			JobsFile:        *jobsFile,
+			IssuesFile:      *issuesFile,
+			FailOn:          failOnExpression,
+			ExitThreshold:   *exitThreshold,
+			AttentionDays:   *attentionDays,
+			FilterRules:     filterRules,
+			Repos:           githubRepos,
+			Search:          strings.TrimSpace(*search),
+			GithubAPI:       *githubAPI,
 		},
+		Command:            command,
 		GitHubClient:       ghClient,
+		Fetcher:            fetcher,
 		DataPostProcessing: dataPostProcessing,
 	}
 }
 
-// GetReporters used to get reporters that implement methods like RequestData and Print
-func (m Meta) GetReporters() []CIReport {
-	if m.Flags.SpecificReport == "" {
-		return []CIReport{&GithubReport{}, &TestgridReport{}}
-	} else if m.Flags.SpecificReport == githubReport {
-		return []CIReport{&GithubReport{}}
-	} else if m.Flags.SpecificReport == testgridReport {
-		return []CIReport{&TestgridReport{}}
-	} else {
-		log.Fatalf("Information given via flag -report does not match options [%s, %s]", githubReport, testgridReport)
-	}
-	return nil
+// usageFatalf logs an invalid flag and exits with ExitUsage, so scripts can tell invalid usage from errors while
+// running the report
+func usageFatalf(format string, v ...interface{}) {
+	log.Printf(format, v...)
+	os.Exit(ExitUsage)
 }
 
-// This function is used to split release version input ("1.22, 1.21" => ["1.22", "1.21"])
-func splitReleaseVersionInput(input string) []string {
-	re := regexp.MustCompile(`\d.\d\d`)
-	releaseVersion := []string{}
-
-	for _, e := range strings.Split(input, ",") {
-		if e != "" {
-			trimStr := strings.TrimSpace(e)
-			found := re.MatchString(trimStr)
-
-			if found {
-				releaseVersion = append(releaseVersion, trimStr)
-			} else {
-				fmt.Printf("%s does not match\n", trimStr)
-			}
+// containsString checks if a string is part of a list
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
 		}
 	}
-	return releaseVersion
+	return false
 }
@@ -0,0 +1,179 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// csvListSeparator joins multiple values of a column like sigs or labels
+const csvListSeparator = ";"
+
+var (
+	csvJobsHeader   = []string{"dashboard", "job", "status", "severity", "recent_passes", "recent_runs", "sigs", "failing_tests", "failing_since", "url"}
+	csvIssuesHeader = []string{"repo", "number", "title", "labels", "sigs", "milestone", "created", "updated", "comments", "url"}
+)
+
+// WriteCSV writes one row per failing, flaky or stale testgrid job and one row per github issue. The jobs and issues
+// are written to the files set via -jobs-file and -issues-file, tables without a file are printed (separated by an
+// empty line). With -format tsv the columns are separated by tabs.
+func WriteCSV(meta Meta, report Report) error {
+	jobs, issues := csvRows(report)
+	tables := []struct {
+		path   string
+		header []string
+		rows   [][]string
+	}{
+		{meta.Flags.JobsFile, csvJobsHeader, jobs},
+		{meta.Flags.IssuesFile, csvIssuesHeader, issues},
+	}
+	printed := 0
+	for _, table := range tables {
+		if table.path != "" {
+			if err := writeCSVFile(meta, table.path, table.header, table.rows); err != nil {
+				return err
+			}
+			continue
+		}
+		if printed != 0 {
+			fmt.Println()
+		}
+		if err := writeCSV(meta, os.Stdout, table.header, table.rows); err != nil {
+			return err
+		}
+		printed++
+	}
+	return nil
+}
+
+// csvRows returns the rows of the testgrid jobs and the github issues of the report. Failing and flaky jobs without
+// details (left out with -short or filtered by -sig) are taken from the summary, like NewJUnitReport does.
+func csvRows(report Report) ([][]string, [][]string) {
+	jobs := [][]string{}
+	issues := [][]string{}
+	for _, reportData := range report {
+		for _, field := range reportData.Data {
+			detailed := map[string]bool{}
+			for _, record := range field.Records {
+				if record.ID == testgridReportDetails {
+					detailed[record.Title] = true
+				}
+			}
+			for _, record := range field.Records {
+				switch reportData.Name {
+				case testgridReport:
+					if record.ID == testgridReportSummary {
+						for _, status := range []overallStatus{failing, flaky} {
+							summaryJobs := record.FailingJobs
+							if status == flaky {
+								summaryJobs = record.FlakyJobs
+							}
+							for _, job := range summaryJobs {
+								if !detailed[job] {
+									jobs = append(jobs, []string{field.Title, job, strings.ToLower(string(status)), "", "", "", "", "", "", ""})
+								}
+							}
+						}
+						continue
+					}
+					jobs = append(jobs, []string{
+						field.Title,
+						record.Title,
+						strings.ToLower(record.Status),
+						strconv.Itoa(int(record.Severity)),
+						strconv.Itoa(record.RecentPasses),
+						strconv.Itoa(record.RecentRuns),
+						strings.Join(record.Sigs, csvListSeparator),
+						strconv.Itoa(len(record.FailingTests)),
+						csvTime(record.FailingSince),
+						record.URL,
+					})
+				case githubReport:
+					issues = append(issues, []string{
+						record.Repo,
+						strconv.FormatInt(record.ID, 10),
+						record.Title,
+						strings.Join(record.Labels, csvListSeparator),
+						strings.Join(record.Sigs, csvListSeparator),
+						record.Milestone,
+						csvTime(record.CreatedAt),
+						csvTime(record.UpdatedAt),
+						strconv.FormatInt(record.Comments, 10),
+						record.URL,
+					})
+				}
+			}
+		}
+	}
+	return jobs, issues
+}
+
+func writeCSVFile(meta Meta, path string, header []string, rows [][]string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeCSV(meta, f, header, rows); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeCSV(meta Meta, w io.Writer, header []string, rows [][]string) error {
+	cw := csv.NewWriter(w)
+	if meta.Flags.Format == formatTSV {
+		cw.Comma = '\t'
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = csvCell(cell)
+		}
+		if err := cw.Write(cells); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvCell escapes cells spreadsheets would evaluate as formula (like an issue titled '=HYPERLINK(...)') by
+// prefixing them with a quote
+func csvCell(cell string) string {
+	if cell != "" && strings.ContainsAny(cell[:1], "=+-@\t\r") {
+		return "'" + cell
+	}
+	return cell
+}
+
+// csvTime formats a time as RFC 3339 (UTC), spreadsheets parse it as date
+func csvTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestCSVCell(t *testing.T) {
+	for _, tc := range []struct {
+		cell string
+		want string
+	}{
+		{cell: "", want: ""},
+		{cell: "Failing test: e2e", want: "Failing test: e2e"},
+		{cell: "1", want: "1"},
+		{cell: "=HYPERLINK(\"https://example.com\")", want: "'=HYPERLINK(\"https://example.com\")"},
+		{cell: "+1", want: "'+1"},
+		{cell: "-1", want: "'-1"},
+		{cell: "@SUM(A1)", want: "'@SUM(A1)"},
+		{cell: "a=b", want: "a=b"},
+		{cell: "\t=1+1", want: "'\t=1+1"},
+		{cell: "\r=1+1", want: "'\r=1+1"},
+	} {
+		if got := csvCell(tc.cell); got != tc.want {
+			t.Errorf("csvCell(%q) = %q, want %q", tc.cell, got, tc.want)
+		}
+	}
+}
+
+func TestWriteCSVEscapesFormulas(t *testing.T) {
+	for _, tc := range []struct {
+		format string
+		want   string
+	}{
+		{format: formatCSV, want: "repo,title\nkubernetes/kubernetes,'=1+1\n"},
+		{format: formatTSV, want: "repo\ttitle\nkubernetes/kubernetes\t'=1+1\n"},
+	} {
+		var b bytes.Buffer
+		meta := Meta{Flags: metaFlags{Format: tc.format}}
+		if err := writeCSV(meta, &b, []string{"repo", "title"}, [][]string{{"kubernetes/kubernetes", "=1+1"}}); err != nil {
+			t.Fatalf("writeCSV() error = %v", err)
+		}
+		if got := b.String(); got != tc.want {
+			t.Errorf("writeCSV() with format %s = %q, want %q", tc.format, got, tc.want)
+		}
+	}
+}
+
+func TestCSVRowsShort(t *testing.T) {
+	jobs, issues := csvRows(shortReport)
+	wantJobs := [][]string{
+		{"Master-Blocking", "kind-master-parallel", "failing", "", "", "", "", "", "", ""},
+		{"Master-Blocking", "gce-cos-master-default", "flaky", "", "", "", "", "", "", ""},
+		{"Master-Informing", "gce-master-scale", "flaky", "", "", "", "", "", "", ""},
+	}
+	if !reflect.DeepEqual(jobs, wantJobs) {
+		t.Errorf("csvRows() jobs = %q, want %q", jobs, wantJobs)
+	}
+	if len(issues) != 2 {
+		t.Errorf("csvRows() got %d issues, want 2", len(issues))
+	}
+}
+
+func TestCSVRowsDetails(t *testing.T) {
+	report := Report{{Name: testgridReport, Data: []ReportDataField{{Title: "Master-Blocking", Records: []ReportDataRecord{
+		{ID: testgridReportSummary, FailingJobs: []string{"kind-master-parallel"}, FlakyJobs: []string{"gce-cos-master-default"}},
+		{ID: testgridReportDetails, Title: "kind-master-parallel", Status: string(failing), Severity: HighSeverity, RecentPasses: 1, RecentRuns: 10, Sigs: []string{"network", "node"}, FailingTests: []string{"a", "b"}, URL: "https://testgrid.k8s.io/sig-release-master-blocking#kind-master-parallel"},
+	}}}}}
+	jobs, _ := csvRows(report)
+	// the failing job is written with its details once, the flaky job without details is taken from the summary
+	wantJobs := [][]string{
+		{"Master-Blocking", "gce-cos-master-default", "flaky", "", "", "", "", "", "", ""},
+		{"Master-Blocking", "kind-master-parallel", "failing", "3", "1", "10", "network;node", "2", "", "https://testgrid.k8s.io/sig-release-master-blocking#kind-master-parallel"},
+	}
+	if !reflect.DeepEqual(jobs, wantJobs) {
+		t.Errorf("csvRows() jobs = %q, want %q", jobs, wantJobs)
+	}
+}
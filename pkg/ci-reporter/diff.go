@@ -0,0 +1,211 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ReportChangeKind describes how a record changed between two reports
+type ReportChangeKind string
+
+// Changes that are detected between two reports
+const (
+	// JobFailing a testgrid job went red
+	JobFailing ReportChangeKind = "job_failing"
+	// JobFlaky a testgrid job started flaking
+	JobFlaky ReportChangeKind = "job_flaky"
+	// JobRecovered a failing or flaky testgrid job is passing again
+	JobRecovered ReportChangeKind = "job_recovered"
+	// IssueNew a new issue has been filed (or got a matching label)
+	IssueNew ReportChangeKind = "issue_new"
+	// IssueClosed an issue has been closed (or lost its matching label)
+	IssueClosed ReportChangeKind = "issue_closed"
+)
+
+// ReportChange a record that changed between two reports
+type ReportChange struct {
+	Kind ReportChangeKind `json:"kind"`
+	// Report name of the report like 'testgrid'
+	Report string `json:"report"`
+	// Field title of the report data field like 'Master-Blocking'
+	Field          string `json:"field"`
+	ID             int64  `json:"id"`
+	Title          string `json:"title"`
+	URL            string `json:"url"`
+	Status         string `json:"status"`
+	PreviousStatus string `json:"previous_status"`
+	// Repo repository of a github issue like 'kubernetes/kubernetes'
+	Repo string `json:"repo,omitempty"`
+}
+
+// String describes the change in one line
+func (c ReportChange) String() string {
+	switch c.Kind {
+	case JobFailing:
+		return fmt.Sprintf("%s: %s went red (%s)", c.Field, c.Title, c.URL)
+	case JobFlaky:
+		return fmt.Sprintf("%s: %s is flaky (%s)", c.Field, c.Title, c.URL)
+	case JobRecovered:
+		return fmt.Sprintf("%s: %s recovered (%s)", c.Field, c.Title, c.URL)
+	case IssueNew:
+		return fmt.Sprintf("New issue %s %s (%s)", c.IssueReference(), c.Title, c.URL)
+	case IssueClosed:
+		return fmt.Sprintf("Closed issue %s %s (%s)", c.IssueReference(), c.Title, c.URL)
+	}
+	return fmt.Sprintf("%s: %s %s", c.Kind, c.Title, c.URL)
+}
+
+// IssueReference references the issue of the change like '#105965' or 'kubernetes-sigs/kind#2312'
+func (c ReportChange) IssueReference() string {
+	return issueReference(ReportDataRecord{ID: c.ID, Repo: c.Repo})
+}
+
+// diffRecord a record and the report & field it belongs to
+type diffRecord struct {
+	Report string
+	Field  string
+	Record ReportDataRecord
+}
+
+// DiffReports returns the changes of failing & flaky testgrid jobs and github issues from previous to current.
+// Fields that could not be requested completely in either report are skipped, so a failed request is not
+// reported as recovered jobs or closed issues.
+func DiffReports(previous Report, current Report) []ReportChange {
+	prevRecords, prevIncomplete := diffRecords(previous)
+	currRecords, currIncomplete := diffRecords(current)
+	changes := []ReportChange{}
+
+	for key, curr := range currRecords {
+		scope := diffScope(curr.Report, curr.Field)
+		if prevIncomplete[scope] || currIncomplete[scope] {
+			continue
+		}
+		prev, existed := prevRecords[key]
+		change := ReportChange{Report: curr.Report, Field: curr.Field, ID: curr.Record.ID, Repo: curr.Record.Repo, Title: curr.Record.Title, URL: curr.Record.URL, Status: curr.Record.Status}
+		if existed {
+			change.PreviousStatus = prev.Record.Status
+		}
+		switch curr.Report {
+		case testgridReport:
+			if existed && prev.Record.Status == curr.Record.Status {
+				continue
+			}
+			if curr.Record.Status == string(failing) {
+				change.Kind = JobFailing
+			} else if curr.Record.Status == string(flaky) {
+				change.Kind = JobFlaky
+			} else {
+				continue
+			}
+		default:
+			if existed {
+				continue
+			}
+			change.Kind = IssueNew
+		}
+		changes = append(changes, change)
+	}
+
+	for key, prev := range prevRecords {
+		scope := diffScope(prev.Report, prev.Field)
+		if prevIncomplete[scope] || currIncomplete[scope] {
+			continue
+		}
+		if _, exists := currRecords[key]; exists {
+			continue
+		}
+		// the report (or dashboard) is not part of the current report, nothing can be said about its records
+		if !diffHasScope(current, scope) {
+			continue
+		}
+		change := ReportChange{Report: prev.Report, Field: prev.Field, ID: prev.Record.ID, Repo: prev.Record.Repo, Title: prev.Record.Title, URL: prev.Record.URL, PreviousStatus: prev.Record.Status}
+		if prev.Report == testgridReport {
+			change.Kind = JobRecovered
+			change.Status = string(passing)
+		} else {
+			change.Kind = IssueClosed
+		}
+		changes = append(changes, change)
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if a.Report != b.Report {
+			return a.Report > b.Report
+		}
+		if a.Field != b.Field {
+			return a.Field < b.Field
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.ID != b.ID {
+			return a.ID < b.ID
+		}
+		return a.Title < b.Title
+	})
+	return changes
+}
+
+// diffRecords indexes the failing & flaky testgrid jobs and the github issues of a report, the scopes that could
+// not be requested completely are returned too
+func diffRecords(report Report) (map[string]diffRecord, map[string]bool) {
+	records := map[string]diffRecord{}
+	incomplete := map[string]bool{}
+	for _, reportData := range report {
+		for _, field := range reportData.Data {
+			scope := diffScope(reportData.Name, field.Title)
+			if field.Error != "" {
+				incomplete[scope] = true
+			}
+			for _, record := range field.Records {
+				switch reportData.Name {
+				case testgridReport:
+					if record.ID != testgridReportDetails {
+						continue
+					}
+					records[scope+"/"+record.Title] = diffRecord{Report: reportData.Name, Field: field.Title, Record: record}
+				default:
+					records[scope+"/"+record.URL] = diffRecord{Report: reportData.Name, Field: field.Title, Record: record}
+				}
+			}
+		}
+	}
+	return records, incomplete
+}
+
+// diffScope testgrid jobs are compared per dashboard, records of other reports (like github issues) per report
+func diffScope(reportName string, fieldTitle string) string {
+	if reportName == testgridReport {
+		return reportName + "/" + fieldTitle
+	}
+	return reportName
+}
+
+// diffHasScope checks if a scope is part of the report
+func diffHasScope(report Report, scope string) bool {
+	for _, reportData := range report {
+		for _, field := range reportData.Data {
+			if diffScope(reportData.Name, field.Title) == scope {
+				return true
+			}
+		}
+	}
+	return false
+}
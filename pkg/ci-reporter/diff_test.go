@@ -0,0 +1,167 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// loadReportFixture reads a report of testdata/diff
+func loadReportFixture(t *testing.T, name string) Report {
+	t.Helper()
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "diff", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report Report
+	if err := json.Unmarshal(b, &report); err != nil {
+		t.Fatalf("could not unmarshal %s: %v", name, err)
+	}
+	return report
+}
+
+// changeSummary reduces changes to kind, field and title
+func changeSummary(changes []ReportChange) []string {
+	summary := []string{}
+	for _, change := range changes {
+		summary = append(summary, string(change.Kind)+" "+change.Field+" "+change.Title)
+	}
+	return summary
+}
+
+func TestDiffReports(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous string
+		current  string
+		want     []string
+	}{
+		{
+			name:     "jobs and issues changed",
+			previous: "previous.json",
+			current:  "current.json",
+			want: []string{
+				"job_failing Master-Blocking kind-master-parallel",
+				"job_recovered Master-Blocking gce-cos-master-default",
+				"job_flaky Master-Informing gce-master-alpha",
+				"issue_closed  [Failing test] gci-gce-serial",
+				"issue_new  Failure test: Volume metrics Ephemeral",
+			},
+		},
+		{
+			name:     "no changes",
+			previous: "current.json",
+			current:  "current.json",
+			want:     []string{},
+		},
+		{
+			name:     "incomplete scopes are skipped",
+			previous: "previous.json",
+			current:  "current-incomplete.json",
+			want: []string{
+				"job_flaky Master-Informing gce-master-alpha",
+			},
+		},
+		{
+			name:     "incomplete previous scopes are skipped",
+			previous: "current-incomplete.json",
+			current:  "previous.json",
+			want: []string{
+				"job_recovered Master-Informing gce-master-alpha",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := changeSummary(DiffReports(loadReportFixture(t, tt.previous), loadReportFixture(t, tt.current)))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffReports() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffReportsStatus(t *testing.T) {
+	changes := DiffReports(loadReportFixture(t, "previous.json"), loadReportFixture(t, "current.json"))
+	if len(changes) == 0 {
+		t.Fatal("DiffReports() returned no changes")
+	}
+	got := changes[0]
+	want := ReportChange{
+		Kind:           JobFailing,
+		Report:         testgridReport,
+		Field:          "Master-Blocking",
+		ID:             testgridReportDetails,
+		Title:          "kind-master-parallel",
+		URL:            "https://testgrid.k8s.io/sig-release-master-blocking#kind-master-parallel",
+		Status:         string(failing),
+		PreviousStatus: string(flaky),
+	}
+	if got != want {
+		t.Errorf("DiffReports()[0] = %+v, want %+v", got, want)
+	}
+}
+
+func TestDiffRecords(t *testing.T) {
+	records, incomplete := diffRecords(loadReportFixture(t, "current-incomplete.json"))
+	wantKeys := []string{
+		"testgrid/Master-Informing/gce-master-scale",
+		"testgrid/Master-Informing/gce-master-alpha",
+		"github/https://github.com/kubernetes/kubernetes/issues/105965",
+	}
+	if len(records) != len(wantKeys) {
+		t.Errorf("diffRecords() returned %d records, want %d", len(records), len(wantKeys))
+	}
+	for _, key := range wantKeys {
+		if _, ok := records[key]; !ok {
+			t.Errorf("diffRecords() is missing %s", key)
+		}
+	}
+	wantIncomplete := map[string]bool{"testgrid/Master-Blocking": true, "github": true}
+	if !reflect.DeepEqual(incomplete, wantIncomplete) {
+		t.Errorf("diffRecords() incomplete = %v, want %v", incomplete, wantIncomplete)
+	}
+}
+
+func TestReportChangeString(t *testing.T) {
+	tests := []struct {
+		change ReportChange
+		want   string
+	}{
+		{
+			change: ReportChange{Kind: JobFailing, Report: testgridReport, Field: "Master-Blocking", Title: "kind-master-parallel", URL: "https://testgrid.k8s.io/sig-release-master-blocking#kind-master-parallel"},
+			want:   "Master-Blocking: kind-master-parallel went red (https://testgrid.k8s.io/sig-release-master-blocking#kind-master-parallel)",
+		},
+		{
+			change: ReportChange{Kind: IssueNew, Report: githubReport, ID: 105965, Repo: defaultGithubRepo, Title: "volume metrics tests failure", URL: "https://github.com/kubernetes/kubernetes/issues/105965"},
+			want:   "New issue #105965 volume metrics tests failure (https://github.com/kubernetes/kubernetes/issues/105965)",
+		},
+		{
+			change: ReportChange{Kind: IssueClosed, Report: githubReport, ID: 2312, Repo: "kubernetes-sigs/kind", Title: "kind cluster fails to start", URL: "https://github.com/kubernetes-sigs/kind/issues/2312"},
+			want:   "Closed issue kubernetes-sigs/kind#2312 kind cluster fails to start (https://github.com/kubernetes-sigs/kind/issues/2312)",
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.change.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
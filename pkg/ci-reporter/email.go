@@ -0,0 +1,239 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// defaultEmailFrom sender of the email if -email-from is not set
+const defaultEmailFrom = "ci-signal-report@localhost"
+
+// BuildEmail builds an RFC 5322 message with a plain text and a html part of the report
+func BuildEmail(meta Meta, report Report, generatedAt time.Time) ([]byte, error) {
+	var text bytes.Buffer
+	if err := emailTextTemplate.Execute(&text, ReportTemplateData{GeneratedAt: generatedAt, Report: report}); err != nil {
+		return nil, err
+	}
+	// The html part looks like the page of the serve command
+	served := ServedReport{GeneratedAt: generatedAt, Reports: []ServedReportData{}}
+	for _, reportData := range report {
+		served.Reports = append(served.Reports, ServedReportData{GeneratedAt: generatedAt, Data: reportData, Error: reportErrors(reportData)})
+	}
+	var html bytes.Buffer
+	if err := serverHTMLTemplate.Execute(&html, served); err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{
+		{"text/plain; charset=utf-8", text.Bytes()},
+		{"text/html; charset=utf-8", html.Bytes()},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qw := quotedprintable.NewWriter(pw)
+		if _, err := qw.Write(part.content); err != nil {
+			return nil, err
+		}
+		if err := qw.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	headers := [][2]string{
+		{"From", emailFrom(meta)},
+		{"To", strings.Join(meta.Flags.EmailTo, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", EmailSubject(report))},
+		{"Date", generatedAt.Format(time.RFC1123Z)},
+		{"Message-ID", emailMessageID(generatedAt)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", fmt.Sprintf("multipart/alternative; boundary=%q", mw.Boundary())},
+	}
+	for _, header := range headers {
+		if header[1] == "" {
+			continue
+		}
+		fmt.Fprintf(&msg, "%s: %s\r\n", header[0], header[1])
+	}
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+// EmailSubject summarizes the failing jobs of the blocking dashboards like 'CI Signal: 2 failing on master-blocking'
+func EmailSubject(report Report) string {
+	failingOn := []string{}
+	blockingDashboards := 0
+	complete := true
+	for _, reportData := range report {
+		complete = complete && reportData.Complete()
+		if reportData.Name != testgridReport {
+			continue
+		}
+		for _, field := range reportData.Data {
+			if !isBlockingDashboard(field.Title) {
+				continue
+			}
+			blockingDashboards++
+			if failingJobs := weeklyDashboard(field).Counts[strings.ToLower(string(failing))]; failingJobs != 0 {
+				failingOn = append(failingOn, fmt.Sprintf("%d failing on %s", failingJobs, strings.ToLower(field.Title)))
+			}
+		}
+	}
+	subject := "CI Signal: report"
+	if len(failingOn) != 0 {
+		subject = "CI Signal: " + strings.Join(failingOn, ", ")
+	} else if blockingDashboards != 0 {
+		subject = "CI Signal: all blocking jobs passing"
+	}
+	if !complete {
+		subject += " (incomplete)"
+	}
+	return subject
+}
+
+func emailFrom(meta Meta) string {
+	if meta.Flags.EmailFrom != "" {
+		return meta.Flags.EmailFrom
+	}
+	return defaultEmailFrom
+}
+
+func emailMessageID(generatedAt time.Time) string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("<%d.%s@ci-signal-report>", generatedAt.UnixNano(), hex.EncodeToString(b))
+}
+
+// SendEmail sends the message to the recipients set via -email-to over the smtp server set via -smtp. STARTTLS is
+// used if the server supports it, credentials are taken from SMTP_USERNAME and SMTP_PASSWORD.
+func SendEmail(ctx context.Context, meta Meta, message []byte) error {
+	host, _, err := net.SplitHostPort(meta.Flags.SMTPAddr)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := withRequestTimeout(ctx, meta.Flags.RequestTimeout)
+	defer cancel()
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", meta.Flags.SMTPAddr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if meta.Env.SMTPUsername != "" {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return fmt.Errorf("%s does not support authentication", meta.Flags.SMTPAddr)
+		}
+		// PlainAuth refuses to send the credentials unencrypted to other hosts than localhost
+		if err := c.Auth(smtp.PlainAuth("", meta.Env.SMTPUsername, meta.Env.SMTPPassword, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(emailAddress(emailFrom(meta))); err != nil {
+		return err
+	}
+	for _, to := range meta.Flags.EmailTo {
+		if err := c.Rcpt(emailAddress(to)); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// emailAddress returns the address of 'Name <address>'
+func emailAddress(s string) string {
+	if start, end := strings.LastIndex(s, "<"), strings.LastIndex(s, ">"); start != -1 && end > start {
+		return s[start+1 : end]
+	}
+	return strings.TrimSpace(s)
+}
+
+var emailTextTemplate = template.Must(template.New("email").Funcs(templateFuncs).Parse(`CI signal report {{ .GeneratedAt.UTC.Format "2006-01-02 15:04 MST" }}
+{{ range .Report }}
+{{- $name := .Name }}
+{{ upper .Name }} REPORT
+{{ range .Data }}
+{{- if .Title }}
+{{ .Title }}
+{{ end }}
+{{- if .Error }}- could not be requested: {{ .Error }}
+{{ end }}
+{{- range .Records }}
+{{- if isSummary $name . }}
+{{- range .Notes }}- {{ stripANSI . }}
+{{ end }}
+{{- else }}- {{ if .Status }}{{ .Status }} {{ end }}{{ if eq $name "github" }}{{ issueReference . }} {{ end }}{{ .Title }}{{ with .Sig }} {{ . }}{{ end }}
+  {{ .URL }}
+{{ end }}
+{{- end }}
+{{- end }}
+{{- end }}`))
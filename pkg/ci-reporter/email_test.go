@@ -0,0 +1,268 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpSession what the smtp stand-in received
+type smtpSession struct {
+	Auth string
+	From string
+	To   []string
+	Data []byte
+}
+
+// newSMTPStandIn accepts a single smtp session on a local port, the credentials are accepted if auth is true
+// (STARTTLS is not offered). The session is sent on the returned channel after QUIT.
+func newSMTPStandIn(t *testing.T, auth bool) (string, <-chan smtpSession) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	sessions := make(chan smtpSession, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tc := textproto.NewConn(conn)
+		session := smtpSession{To: []string{}}
+		_ = tc.PrintfLine("220 localhost ESMTP stand-in")
+		for {
+			line, err := tc.ReadLine()
+			if err != nil {
+				return
+			}
+			verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch verb {
+			case "EHLO":
+				if auth {
+					_ = tc.PrintfLine("250-localhost\r\n250 AUTH PLAIN")
+				} else {
+					_ = tc.PrintfLine("250 localhost")
+				}
+			case "AUTH":
+				session.Auth = strings.TrimPrefix(line, "AUTH ")
+				_ = tc.PrintfLine("235 2.7.0 authenticated")
+			case "MAIL":
+				session.From = strings.TrimSuffix(strings.TrimPrefix(line, "MAIL FROM:<"), ">")
+				_ = tc.PrintfLine("250 2.1.0 ok")
+			case "RCPT":
+				session.To = append(session.To, strings.TrimSuffix(strings.TrimPrefix(line, "RCPT TO:<"), ">"))
+				_ = tc.PrintfLine("250 2.1.5 ok")
+			case "DATA":
+				_ = tc.PrintfLine("354 end data with <CR><LF>.<CR><LF>")
+				if session.Data, err = tc.ReadDotBytes(); err != nil {
+					return
+				}
+				_ = tc.PrintfLine("250 2.0.0 queued")
+			case "QUIT":
+				_ = tc.PrintfLine("221 2.0.0 bye")
+				sessions <- session
+				return
+			default:
+				_ = tc.PrintfLine("502 5.5.2 command not recognized")
+			}
+		}
+	}()
+	return ln.Addr().String(), sessions
+}
+
+// emailReport blocking dashboard with 2 failing jobs, an informing dashboard and a github issue
+var emailReport = Report{
+	{Name: testgridReport, Data: []ReportDataField{
+		{Title: "Master-Blocking", Records: []ReportDataRecord{
+			{ID: testgridReportSummary, Counts: map[string]int{"failing": 2}, Notes: []string{"Failing jobs: 2"}},
+			{ID: testgridReportDetails, Title: "kind-master-parallel", URL: "https://testgrid.k8s.io/sig-release-master-blocking#kind-master-parallel", Status: string(failing)},
+			{ID: testgridReportDetails, Title: "gce-cos-master-default", URL: "https://testgrid.k8s.io/sig-release-master-blocking#gce-cos-master-default", Status: string(failing)},
+		}},
+		{Title: "Master-Informing", Records: []ReportDataRecord{
+			{ID: testgridReportSummary, Counts: map[string]int{"failing": 1}},
+		}},
+	}},
+	{Name: githubReport, Data: []ReportDataField{
+		{Records: []ReportDataRecord{
+			{ID: 105965, Title: "volume metrics tests failure", URL: "https://github.com/kubernetes/kubernetes/issues/105965"},
+		}},
+	}},
+}
+
+func TestEmailSubject(t *testing.T) {
+	incomplete := append(Report{}, emailReport...)
+	incomplete[1] = ReportData{Name: githubReport, Data: []ReportDataField{{Error: "rate limited"}}}
+	tests := []struct {
+		name   string
+		report Report
+		want   string
+	}{
+		{name: "failing blocking jobs", report: emailReport, want: "CI Signal: 2 failing on master-blocking"},
+		{name: "incomplete", report: incomplete, want: "CI Signal: 2 failing on master-blocking (incomplete)"},
+		{
+			name:   "passing blocking jobs",
+			report: Report{{Name: testgridReport, Data: []ReportDataField{{Title: "Master-Blocking", Records: []ReportDataRecord{{ID: testgridReportSummary, Counts: map[string]int{}}}}}}},
+			want:   "CI Signal: all blocking jobs passing",
+		},
+		{name: "no dashboards", report: Report{emailReport[1]}, want: "CI Signal: report"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EmailSubject(tt.report); got != tt.want {
+				t.Errorf("EmailSubject() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendEmail(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		wantAuth string
+	}{
+		{name: "without credentials"},
+		{name: "with credentials", username: "ci-signal", wantAuth: "PLAIN " + base64.StdEncoding.EncodeToString([]byte("\x00ci-signal\x00secret"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, sessions := newSMTPStandIn(t, tt.username != "")
+			meta := Meta{
+				Env: metaEnv{SMTPUsername: tt.username, SMTPPassword: "secret"},
+				Flags: metaFlags{
+					SMTPAddr:       addr,
+					EmailFrom:      "CI Signal <ci-signal@example.com>",
+					EmailTo:        []string{"Release Team <release-team@example.com>", "ci@example.com"},
+					RequestTimeout: 10 * time.Second,
+				},
+			}
+			generatedAt := time.Date(2021, 11, 2, 9, 30, 0, 0, time.UTC)
+			message, err := BuildEmail(meta, emailReport, generatedAt)
+			if err != nil {
+				t.Fatalf("BuildEmail() error = %v", err)
+			}
+			if err := SendEmail(context.Background(), meta, message); err != nil {
+				t.Fatalf("SendEmail() error = %v", err)
+			}
+			var session smtpSession
+			select {
+			case session = <-sessions:
+			case <-time.After(10 * time.Second):
+				t.Fatal("the smtp stand-in did not receive QUIT")
+			}
+
+			if session.Auth != tt.wantAuth {
+				t.Errorf("AUTH = %q, want %q", session.Auth, tt.wantAuth)
+			}
+			if session.From != "ci-signal@example.com" {
+				t.Errorf("MAIL FROM = %q, want ci-signal@example.com", session.From)
+			}
+			if wantTo := []string{"release-team@example.com", "ci@example.com"}; !reflect.DeepEqual(session.To, wantTo) {
+				t.Errorf("RCPT TO = %q, want %q", session.To, wantTo)
+			}
+			checkEmail(t, session.Data)
+		})
+	}
+}
+
+// checkEmail checks the headers and both parts of the email built from emailReport
+func checkEmail(t *testing.T, data []byte) {
+	t.Helper()
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("could not read the message: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatalf("could not decode the subject: %v", err)
+	}
+	headers := map[string]string{
+		"From":         msg.Header.Get("From"),
+		"To":           msg.Header.Get("To"),
+		"Subject":      subject,
+		"Date":         msg.Header.Get("Date"),
+		"MIME-Version": msg.Header.Get("MIME-Version"),
+	}
+	wantHeaders := map[string]string{
+		"From":         "CI Signal <ci-signal@example.com>",
+		"To":           "Release Team <release-team@example.com>, ci@example.com",
+		"Subject":      "CI Signal: 2 failing on master-blocking",
+		"Date":         "Tue, 02 Nov 2021 09:30:00 +0000",
+		"MIME-Version": "1.0",
+	}
+	if !reflect.DeepEqual(headers, wantHeaders) {
+		t.Errorf("headers = %q, want %q", headers, wantHeaders)
+	}
+	if id := msg.Header.Get("Message-ID"); !strings.HasSuffix(id, "@ci-signal-report>") {
+		t.Errorf("Message-ID = %q, want <...@ci-signal-report>", id)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, want multipart/alternative", msg.Header.Get("Content-Type"))
+	}
+	parts := map[string]string{}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		// the reader decodes the quoted-printable parts
+		body, err := ioutil.ReadAll(part)
+		if err != nil {
+			t.Fatalf("could not read the %s part: %v", part.Header.Get("Content-Type"), err)
+		}
+		parts[part.Header.Get("Content-Type")] = string(body)
+	}
+	if len(parts) != 2 {
+		t.Fatalf("got %d parts, want a text and a html part", len(parts))
+	}
+	text := parts["text/plain; charset=utf-8"]
+	for _, want := range []string{
+		"CI signal report 2021-11-02 09:30 UTC",
+		"TESTGRID REPORT",
+		"- FAILING kind-master-parallel\n  https://testgrid.k8s.io/sig-release-master-blocking#kind-master-parallel",
+		"GITHUB REPORT",
+		"- #105965 volume metrics tests failure\n  https://github.com/kubernetes/kubernetes/issues/105965",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text part does not contain %q:\n%s", want, text)
+		}
+	}
+	html := parts["text/html; charset=utf-8"]
+	for _, want := range []string{"kind-master-parallel", "https://github.com/kubernetes/kubernetes/issues/105965"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("html part does not contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import "strings"
+
+// Exit codes of the report, scripts can react on the health of CI without parsing the output
+const (
+	// ExitHealthy no failing or flaky jobs on the blocking dashboards
+	ExitHealthy = 0
+	// ExitError the report could not be run (like a missing GITHUB_AUTH_TOKEN)
+	ExitError = 1
+	// ExitUsage invalid flags or command
+	ExitUsage = 2
+	// ExitFailing failing jobs on the blocking dashboards
+	ExitFailing = 3
+	// ExitFlaky flaky but no failing jobs on the blocking dashboards
+	ExitFlaky = 4
+	// ExitPartial data could not be requested because of upstream errors (and no failing blocking jobs)
+	ExitPartial = 5
+	// ExitFailOn the -fail-on condition is true
+	ExitFailOn = 6
+)
+
+// Thresholds that can be set via -exit-threshold, from lenient to strict
+const (
+	// exitThresholdNone always exits with ExitHealthy
+	exitThresholdNone = "none"
+	// exitThresholdPartial exits with ExitPartial
+	exitThresholdPartial = "partial"
+	// exitThresholdFailing exits with ExitFailing or ExitPartial
+	exitThresholdFailing = "failing"
+	// exitThresholdFlaky exits with ExitFailing, ExitPartial or ExitFlaky
+	exitThresholdFlaky = "flaky"
+)
+
+var exitThresholdOptions = []string{exitThresholdNone, exitThresholdPartial, exitThresholdFailing, exitThresholdFlaky}
+
+// ExitCode returns the exit code that reflects the health of the report. If -fail-on is set only the condition
+// decides (ExitFailOn or ExitHealthy), otherwise the job counts of the blocking dashboards up to the -exit-threshold.
+// The weekly summary always exits with ExitHealthy.
+func ExitCode(meta Meta, report Report) (int, error) {
+	if meta.Command == WeeklyCommand {
+		return ExitHealthy, nil
+	}
+	if meta.Flags.FailOn != nil {
+		failed, err := meta.Flags.FailOn.Eval(report)
+		if err != nil {
+			return ExitError, err
+		}
+		if failed {
+			return ExitFailOn, nil
+		}
+		return ExitHealthy, nil
+	}
+
+	partial, failingJobs, flakyJobs := false, 0, 0
+	for _, reportData := range report {
+		if !reportData.Complete() {
+			partial = true
+		}
+		if reportData.Name != testgridReport {
+			continue
+		}
+		for _, field := range reportData.Data {
+			if !isBlockingDashboard(field.Title) {
+				continue
+			}
+			// the summary is part of the report with -short and counts the jobs of all sigs
+			for _, record := range field.Records {
+				if record.ID == testgridReportSummary {
+					failingJobs += record.Counts[strings.ToLower(string(failing))]
+					flakyJobs += record.Counts[strings.ToLower(string(flaky))]
+				}
+			}
+		}
+	}
+
+	threshold := meta.Flags.ExitThreshold
+	switch {
+	case failingJobs != 0 && (threshold == exitThresholdFailing || threshold == exitThresholdFlaky):
+		return ExitFailing, nil
+	case partial && threshold != exitThresholdNone:
+		return ExitPartial, nil
+	case flakyJobs != 0 && threshold == exitThresholdFlaky:
+		return ExitFlaky, nil
+	}
+	return ExitHealthy, nil
+}
+
+// ExitCodeDescription describes an exit code
+func ExitCodeDescription(code int) string {
+	switch code {
+	case ExitHealthy:
+		return "healthy"
+	case ExitError:
+		return "error"
+	case ExitUsage:
+		return "invalid usage"
+	case ExitFailing:
+		return "failing jobs on the blocking dashboards"
+	case ExitFlaky:
+		return "flaky jobs on the blocking dashboards"
+	case ExitPartial:
+		return "the report is incomplete because of upstream errors"
+	case ExitFailOn:
+		return "the -fail-on condition is true"
+	}
+	return "unknown"
+}
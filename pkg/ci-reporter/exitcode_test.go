@@ -0,0 +1,110 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import "testing"
+
+// dashboardReport returns a testgrid report of one dashboard with a summary of the job counts
+func dashboardReport(title string, failingJobs int, flakyJobs int, details ...ReportDataRecord) Report {
+	summary := ReportDataRecord{ID: testgridReportSummary, Counts: map[string]int{"failing": failingJobs, "flaky": flakyJobs}}
+	return Report{{Name: testgridReport, Data: []ReportDataField{{Title: title, Records: append([]ReportDataRecord{summary}, details...)}}}}
+}
+
+func TestExitCode(t *testing.T) {
+	withFailing := dashboardReport("Master-Blocking", 1, 1, ReportDataRecord{Title: "kind-master-parallel", Status: string(failing)})
+	flakyOnly := dashboardReport("Master-Blocking", 0, 2)
+	informingFailing := dashboardReport("Master-Informing", 1, 0)
+	incomplete := append(dashboardReport("Master-Blocking", 0, 1), ReportData{Name: githubReport, Data: []ReportDataField{{Error: "502 Bad Gateway"}}})
+	// -sig sig-node filtered out the failing job of sig-network, the summary still counts it
+	sigFiltered := dashboardReport("Master-Blocking", 1, 0)
+	failOn, err := ParseFailOn("blocking.failing>0")
+	if err != nil {
+		t.Fatalf("ParseFailOn() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		command   string
+		threshold string
+		failOn    *FailOnExpression
+		report    Report
+		want      int
+	}{
+		{name: "healthy", threshold: exitThresholdFlaky, report: dashboardReport("Master-Blocking", 0, 0), want: ExitHealthy},
+		{name: "threshold none", threshold: exitThresholdNone, report: withFailing, want: ExitHealthy},
+		{name: "threshold partial failing", threshold: exitThresholdPartial, report: withFailing, want: ExitHealthy},
+		{name: "threshold partial incomplete", threshold: exitThresholdPartial, report: incomplete, want: ExitPartial},
+		{name: "threshold failing failing", threshold: exitThresholdFailing, report: withFailing, want: ExitFailing},
+		{name: "threshold failing flaky only", threshold: exitThresholdFailing, report: flakyOnly, want: ExitHealthy},
+		{name: "threshold failing incomplete", threshold: exitThresholdFailing, report: incomplete, want: ExitPartial},
+		{name: "threshold flaky failing", threshold: exitThresholdFlaky, report: withFailing, want: ExitFailing},
+		{name: "threshold flaky flaky only", threshold: exitThresholdFlaky, report: flakyOnly, want: ExitFlaky},
+		// incomplete data could hide failing jobs, so it is reported before flaky jobs
+		{name: "threshold flaky incomplete", threshold: exitThresholdFlaky, report: incomplete, want: ExitPartial},
+		{name: "informing dashboards are ignored", threshold: exitThresholdFlaky, report: informingFailing, want: ExitHealthy},
+		{name: "short", threshold: exitThresholdFailing, report: shortReport, want: ExitFailing},
+		{name: "sig filtered", threshold: exitThresholdFailing, report: sigFiltered, want: ExitFailing},
+		{name: "weekly", command: WeeklyCommand, threshold: exitThresholdFlaky, report: withFailing, want: ExitHealthy},
+		{name: "fail-on true", threshold: exitThresholdNone, failOn: failOn, report: withFailing, want: ExitFailOn},
+		// only the condition decides, the threshold is ignored
+		{name: "fail-on false", threshold: exitThresholdFlaky, failOn: failOn, report: flakyOnly, want: ExitHealthy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := Meta{Command: tt.command, Flags: metaFlags{ExitThreshold: tt.threshold, FailOn: tt.failOn}}
+			got, err := ExitCode(meta, tt.report)
+			if err != nil {
+				t.Fatalf("ExitCode() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ExitCode() = %d (%s), want %d (%s)", got, ExitCodeDescription(got), tt.want, ExitCodeDescription(tt.want))
+			}
+		})
+	}
+}
+
+func TestExitCodeFailOnError(t *testing.T) {
+	failOn, err := ParseFailOn("release-blocking.failing>0")
+	if err != nil {
+		t.Fatalf("ParseFailOn() error = %v", err)
+	}
+	got, err := ExitCode(Meta{Flags: metaFlags{FailOn: failOn}}, shortReport)
+	if err == nil || got != ExitError {
+		t.Errorf("ExitCode() = %d, %v, want %d and an error", got, err, ExitError)
+	}
+}
+
+func TestExitCodeDescription(t *testing.T) {
+	tests := []struct {
+		code int
+		want string
+	}{
+		{code: ExitHealthy, want: "healthy"},
+		{code: ExitError, want: "error"},
+		{code: ExitUsage, want: "invalid usage"},
+		{code: ExitFailing, want: "failing jobs on the blocking dashboards"},
+		{code: ExitFlaky, want: "flaky jobs on the blocking dashboards"},
+		{code: ExitPartial, want: "the report is incomplete because of upstream errors"},
+		{code: ExitFailOn, want: "the -fail-on condition is true"},
+		{code: 42, want: "unknown"},
+	}
+	for _, tt := range tests {
+		if got := ExitCodeDescription(tt.code); got != tt.want {
+			t.Errorf("ExitCodeDescription(%d) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,363 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FailOnExpression a condition on the report set via -fail-on like 'blocking.failing>0 || severity>=high'.
+//
+// Variables are the number of jobs by status ('failing', 'flaky', 'stale', 'passing', 'total') and the highest
+// 'severity' of the failing & flaky jobs, both of all sigs (also with -short and -sig). Without prefix they refer to all dashboards, with the prefix 'blocking.',
+// 'informing.' or the lowercase dashboard name (like 'master-blocking.') to some of them. 'issues' is the number of
+// github issues, 'incomplete' is 1 if data could not be requested and 'high', 'medium' & 'light' are the severities.
+// Comparisons (>, >=, <, <=, ==, !=) can be combined with &&, ||, ! and parentheses.
+type FailOnExpression struct {
+	text string
+	root failOnNode
+}
+
+// failOnNode evaluates to a number, conditions evaluate to 1 (true) or 0 (false)
+type failOnNode interface {
+	eval(vars map[string]float64) (float64, error)
+}
+
+type failOnNumber float64
+
+type failOnVariable string
+
+type failOnNot struct {
+	node failOnNode
+}
+
+type failOnBinary struct {
+	op          string
+	left, right failOnNode
+}
+
+func (n failOnNumber) eval(vars map[string]float64) (float64, error) {
+	return float64(n), nil
+}
+
+func (n failOnVariable) eval(vars map[string]float64) (float64, error) {
+	v, ok := vars[string(n)]
+	if !ok {
+		return 0, fmt.Errorf("unknown variable %q", string(n))
+	}
+	return v, nil
+}
+
+func (n failOnNot) eval(vars map[string]float64) (float64, error) {
+	v, err := n.node.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	return failOnBool(v == 0), nil
+}
+
+func (n failOnBinary) eval(vars map[string]float64) (float64, error) {
+	left, err := n.left.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	// && and || short circuit, so a variable of a dashboard that is not part of the report can be guarded
+	if n.op == "&&" && left == 0 {
+		return 0, nil
+	}
+	if n.op == "||" && left != 0 {
+		return 1, nil
+	}
+	right, err := n.right.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	switch n.op {
+	case "&&", "||":
+		return failOnBool(right != 0), nil
+	case ">":
+		return failOnBool(left > right), nil
+	case ">=":
+		return failOnBool(left >= right), nil
+	case "<":
+		return failOnBool(left < right), nil
+	case "<=":
+		return failOnBool(left <= right), nil
+	case "==":
+		return failOnBool(left == right), nil
+	case "!=":
+		return failOnBool(left != right), nil
+	}
+	return 0, fmt.Errorf("unknown operator %q", n.op)
+}
+
+func failOnBool(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// ParseFailOn parses a -fail-on expression
+func ParseFailOn(text string) (*FailOnExpression, error) {
+	tokens, err := failOnTokens(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &failOnParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	return &FailOnExpression{text: text, root: root}, nil
+}
+
+// String returns the expression as it has been given
+func (e *FailOnExpression) String() string {
+	return e.text
+}
+
+// Eval checks if the expression is true for the report
+func (e *FailOnExpression) Eval(report Report) (bool, error) {
+	v, err := e.root.eval(failOnVariables(report))
+	return v != 0, err
+}
+
+// failOnVariables returns the values of all variables that can be used in an expression
+func failOnVariables(report Report) map[string]float64 {
+	statuses := []overallStatus{total, passing, failing, flaky, stale}
+	vars := map[string]float64{
+		"high":       float64(HighSeverity),
+		"medium":     float64(MediumSeverity),
+		"light":      float64(LightSeverity),
+		"issues":     0,
+		"incomplete": 0,
+	}
+	for _, prefix := range []string{"", "blocking.", "informing."} {
+		initFailOnVariables(vars, prefix, statuses)
+	}
+	for _, reportData := range report {
+		if !reportData.Complete() {
+			vars["incomplete"] = 1
+		}
+		for _, field := range reportData.Data {
+			if reportData.Name == githubReport {
+				vars["issues"] += float64(len(field.Records))
+				continue
+			}
+			if reportData.Name != testgridReport {
+				continue
+			}
+			dashboard := strings.ToLower(field.Title)
+			prefixes := []string{"", dashboard + "."}
+			if isBlockingDashboard(dashboard) {
+				prefixes = append(prefixes, "blocking.")
+			} else if strings.HasSuffix(dashboard, "informing") {
+				prefixes = append(prefixes, "informing.")
+			}
+			initFailOnVariables(vars, dashboard+".", statuses)
+			for _, record := range field.Records {
+				if record.ID != testgridReportSummary {
+					continue
+				}
+				for _, prefix := range prefixes {
+					for _, status := range statuses {
+						vars[prefix+strings.ToLower(string(status))] += float64(record.Counts[strings.ToLower(string(status))])
+					}
+					if float64(record.Severity) > vars[prefix+"severity"] {
+						vars[prefix+"severity"] = float64(record.Severity)
+					}
+				}
+			}
+		}
+	}
+	return vars
+}
+
+// initFailOnVariables sets the variables of a prefix to 0, a dashboard that could not be requested has no jobs
+func initFailOnVariables(vars map[string]float64, prefix string, statuses []overallStatus) {
+	for _, status := range statuses {
+		vars[prefix+strings.ToLower(string(status))] = 0
+	}
+	vars[prefix+"severity"] = 0
+}
+
+// isFailOnVariable checks if name is one of the variables failOnVariables returns, dashboard prefixes are only known
+// when the report has been requested
+func isFailOnVariable(name string) bool {
+	switch name {
+	case "high", "medium", "light", "issues", "incomplete":
+		return true
+	}
+	prefix, variable := "", name
+	if i := strings.LastIndex(name, "."); i != -1 {
+		prefix, variable = name[:i], name[i+1:]
+		if prefix == "" {
+			return false
+		}
+	}
+	for _, status := range []overallStatus{total, passing, failing, flaky, stale} {
+		if variable == strings.ToLower(string(status)) {
+			return true
+		}
+	}
+	return variable == "severity"
+}
+
+// failOnTokens splits an expression into operators, parentheses and operands (numbers & variables)
+func failOnTokens(text string) ([]string, error) {
+	tokens := []string{}
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, string(c))
+			i++
+		case strings.HasPrefix(text[i:], "&&") || strings.HasPrefix(text[i:], "||") || strings.HasPrefix(text[i:], ">=") ||
+			strings.HasPrefix(text[i:], "<=") || strings.HasPrefix(text[i:], "==") || strings.HasPrefix(text[i:], "!="):
+			tokens = append(tokens, text[i:i+2])
+			i += 2
+		case c == '>' || c == '<' || c == '!':
+			tokens = append(tokens, string(c))
+			i++
+		case isFailOnOperandChar(c):
+			start := i
+			for i < len(text) && isFailOnOperandChar(text[i]) {
+				i++
+			}
+			tokens = append(tokens, strings.ToLower(text[start:i]))
+		default:
+			return nil, fmt.Errorf("unexpected %q at position %d", c, i+1)
+		}
+	}
+	return tokens, nil
+}
+
+func isFailOnOperandChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '-' || c == '_'
+}
+
+// failOnParser recursive descent parser: or = and {'||' and}, and = unary {'&&' unary},
+// unary = '!' unary | comparison, comparison = operand [op operand], operand = '(' or ')' | number | variable
+type failOnParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *failOnParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *failOnParser) parseOr() (failOnNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = failOnBinary{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *failOnParser) parseAnd() (failOnNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = failOnBinary{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *failOnParser) parseUnary() (failOnNode, error) {
+	if p.peek() == "!" {
+		p.pos++
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return failOnNot{node: node}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *failOnParser) parseComparison() (failOnNode, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	switch op := p.peek(); op {
+	case ">", ">=", "<", "<=", "==", "!=":
+		p.pos++
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return failOnBinary{op: op, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+func (p *failOnParser) parseOperand() (failOnNode, error) {
+	token := p.peek()
+	switch {
+	case token == "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	case token == "(":
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return node, nil
+	case isFailOnOperandChar(token[0]):
+		p.pos++
+		if n, err := strconv.ParseFloat(token, 64); err == nil {
+			return failOnNumber(n), nil
+		}
+		if !isFailOnVariable(token) {
+			return nil, fmt.Errorf("unknown variable %q", token)
+		}
+		return failOnVariable(token), nil
+	}
+	return nil, fmt.Errorf("unexpected %q", token)
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"strings"
+	"testing"
+)
+
+// shortReport report like -short returns it, the dashboards have summaries only
+var shortReport = Report{
+	{Name: testgridReport, Data: []ReportDataField{
+		{Title: "Master-Blocking", Records: []ReportDataRecord{
+			{ID: testgridReportSummary, Severity: HighSeverity, Counts: map[string]int{"total": 3, "passing": 1, "failing": 1, "flaky": 1}, PassingJobs: []string{"build-master"}, FailingJobs: []string{"kind-master-parallel"}, FlakyJobs: []string{"gce-cos-master-default"}},
+		}},
+		{Title: "Master-Informing", Records: []ReportDataRecord{
+			{ID: testgridReportSummary, Severity: LightSeverity, Counts: map[string]int{"total": 1, "flaky": 1}, FlakyJobs: []string{"gce-master-scale"}},
+		}},
+	}},
+	{Name: githubReport, Data: []ReportDataField{{Records: []ReportDataRecord{{ID: 105965}, {ID: 106139}}}}},
+}
+
+func TestParseFailOn(t *testing.T) {
+	tests := []struct {
+		expression string
+		wantErr    string
+	}{
+		{expression: "blocking.failing>0 || severity>=high"},
+		{expression: "!(master-informing.flaky < 2) && issues != 0"},
+		{expression: "blocking.failng>0", wantErr: `unknown variable "blocking.failng"`},
+		{expression: "failures>0", wantErr: `unknown variable "failures"`},
+		{expression: ".failing>0", wantErr: `unknown variable ".failing"`},
+		{expression: "blocking.issues>0", wantErr: `unknown variable "blocking.issues"`},
+		{expression: "failing>", wantErr: "unexpected end of expression"},
+		{expression: "(failing>0", wantErr: "missing )"},
+		{expression: "failing>0 $", wantErr: "unexpected '$' at position 11"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			_, err := ParseFailOn(tt.expression)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("ParseFailOn() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("ParseFailOn() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFailOnEval(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       bool
+		wantErr    bool
+	}{
+		{expression: "blocking.failing>0", want: true},
+		{expression: "informing.failing>0", want: false},
+		// the severity is taken from the summary, the report has no details
+		{expression: "severity>=high", want: true},
+		{expression: "informing.severity>light", want: false},
+		{expression: "master-blocking.flaky==1 && master-informing.flaky==1", want: true},
+		{expression: "flaky==2 && total==4 && issues==2 && incomplete==0", want: true},
+		// a dashboard that is not part of the report can be guarded
+		{expression: "master-informing.flaky>1 && release-blocking.failing>0", want: false},
+		{expression: "release-blocking.failing>0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			expression, err := ParseFailOn(tt.expression)
+			if err != nil {
+				t.Fatalf("ParseFailOn() error = %v", err)
+			}
+			got, err := expression.Eval(shortReport)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Eval() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Eval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
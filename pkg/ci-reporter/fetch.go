@@ -0,0 +1,320 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	fetchUserAgent = "ci-signal-report (+https://github.com/alenkacz/ci-signal-report)"
+	// maxRateLimitWait requests are not retried if the rate limit resets later than this
+	maxRateLimitWait = time.Minute
+)
+
+// FetcherOptions configure the shared http layer
+type FetcherOptions struct {
+	// Concurrency maximum number of requests in flight
+	Concurrency int
+	// Retries number of retries on network errors, 5xx responses and exceeded rate limits
+	Retries int
+	// Backoff base duration to wait before a retry, it is doubled with every attempt and jittered
+	Backoff time.Duration
+	// RequestTimeout limits the time of a single request attempt (0 means no limit)
+	RequestTimeout time.Duration
+	// CacheDir directory GET responses are cached in, they are revalidated with conditional requests ('' disables the cache)
+	CacheDir string
+	// MaxAge cached responses younger than this are served without asking the upstream
+	MaxAge time.Duration
+}
+
+// Fetcher shared http layer that is used for every upstream request (github & testgrid)
+type Fetcher struct {
+	opts   FetcherOptions
+	client *http.Client
+	slots  chan struct{}
+	cache  *httpCache
+
+	// metrics, accessed atomically
+	requests    int64
+	retries     int64
+	failures    int64
+	bytes       int64
+	cacheHits   int64
+	notModified int64
+}
+
+// FetchStats metrics about the requests sent by a Fetcher
+type FetchStats struct {
+	// Requests number of request attempts (including retries)
+	Requests int64 `json:"requests"`
+	// Retries number of retried attempts
+	Retries int64 `json:"retries"`
+	// Failures number of requests that failed after all retries
+	Failures int64 `json:"failures"`
+	// Bytes number of bytes received (before decompression)
+	Bytes int64 `json:"bytes"`
+	// CacheHits number of requests that were served from the cache without asking the upstream
+	CacheHits int64 `json:"cache_hits"`
+	// NotModified number of conditional requests that were answered with 304 Not Modified
+	NotModified int64 `json:"not_modified"`
+}
+
+// FetchResponse response of a request, the body has already been read and decompressed
+type FetchResponse struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte
+}
+
+// NewFetcher creates a Fetcher
+func NewFetcher(opts FetcherOptions) *Fetcher {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.Retries < 0 {
+		opts.Retries = 0
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = 500 * time.Millisecond
+	}
+	f := &Fetcher{
+		opts:   opts,
+		client: &http.Client{},
+		slots:  make(chan struct{}, opts.Concurrency),
+	}
+	if opts.CacheDir != "" {
+		f.cache = &httpCache{dir: opts.CacheDir}
+	}
+	return f
+}
+
+// Stats returns the metrics of all requests sent so far
+func (f *Fetcher) Stats() FetchStats {
+	return FetchStats{
+		Requests:    atomic.LoadInt64(&f.requests),
+		Retries:     atomic.LoadInt64(&f.retries),
+		Failures:    atomic.LoadInt64(&f.failures),
+		Bytes:       atomic.LoadInt64(&f.bytes),
+		CacheHits:   atomic.LoadInt64(&f.cacheHits),
+		NotModified: atomic.LoadInt64(&f.notModified),
+	}
+}
+
+// Get sends a GET request, see Do. If the cache is enabled, fresh responses are served from the cache and
+// cached responses are revalidated with If-None-Match / If-Modified-Since.
+func (f *Fetcher) Get(ctx context.Context, url string, header http.Header) (*FetchResponse, error) {
+	if f.cache == nil {
+		return f.Do(ctx, "GET", url, header, nil)
+	}
+	authHash := cacheAuthHash(header)
+	entry, cached := f.cache.get(url, authHash)
+	if cached && entry.fresh(f.opts.MaxAge) {
+		atomic.AddInt64(&f.cacheHits, 1)
+		return entry.response(), nil
+	}
+	conditionalHeader := http.Header{}
+	for k, values := range header {
+		conditionalHeader[k] = values
+	}
+	if cached {
+		if entry.ETag != "" {
+			conditionalHeader.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			conditionalHeader.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+	resp, err := f.Do(ctx, "GET", url, conditionalHeader, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cached && resp.StatusCode == http.StatusNotModified {
+		atomic.AddInt64(&f.notModified, 1)
+		entry.StoredAt = time.Now()
+		// a failing cache write only costs a full request next time
+		_ = f.cache.put(*entry)
+		return entry.response(), nil
+	}
+	if resp.StatusCode == http.StatusOK && (resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != "" || f.opts.MaxAge > 0) {
+		_ = f.cache.put(httpCacheEntry{
+			URL:          url,
+			AuthHash:     authHash,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			StoredAt:     time.Now(),
+			Body:         resp.Body,
+		})
+	}
+	return resp, nil
+}
+
+// Do sends a request, every attempt waits for a free slot if the concurrency limit is reached.
+// Network errors, 5xx responses and exceeded rate limits are retried with a jittered exponential backoff,
+// any other response is returned to the caller regardless of its status code. The slot is released while waiting
+// for the next attempt, so a request that backs off does not hold up the others.
+func (f *Fetcher) Do(ctx context.Context, method string, url string, header http.Header, body []byte) (*FetchResponse, error) {
+	var lastErr error
+	for attempt := 0; attempt <= f.opts.Retries; attempt++ {
+		if attempt > 0 {
+			atomic.AddInt64(&f.retries, 1)
+		}
+		if err := f.acquire(ctx); err != nil {
+			if attempt == 0 {
+				return nil, err
+			}
+			break
+		}
+		resp, err := f.send(ctx, method, url, header, body)
+		f.release()
+		wait := f.backoff(attempt)
+		switch {
+		case err != nil:
+			lastErr = err
+		case resp.StatusCode >= 500:
+			lastErr = fmt.Errorf("%s %s returned %s", method, url, resp.Status)
+		case isRateLimited(resp):
+			lastErr = fmt.Errorf("%s %s exceeded the rate limit", method, url)
+			wait = rateLimitWait(resp, wait)
+			if wait > maxRateLimitWait {
+				atomic.AddInt64(&f.failures, 1)
+				return nil, fmt.Errorf("%v, it resets in %s", lastErr, wait.Round(time.Second))
+			}
+		default:
+			return resp, nil
+		}
+		if ctx.Err() != nil || attempt == f.opts.Retries {
+			break
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+		}
+	}
+	atomic.AddInt64(&f.failures, 1)
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("%s %s: %v", method, url, ctx.Err())
+	}
+	return nil, lastErr
+}
+
+// acquire waits for a free slot
+func (f *Fetcher) acquire(ctx context.Context) error {
+	select {
+	case f.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release frees the slot taken by acquire
+func (f *Fetcher) release() {
+	<-f.slots
+}
+
+// send sends a single request attempt
+func (f *Fetcher) send(ctx context.Context, method string, url string, header http.Header, body []byte) (*FetchResponse, error) {
+	ctx, cancel := withRequestTimeout(ctx, f.opts.RequestTimeout)
+	defer cancel()
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("error on creating http request: %v", err)
+	}
+	for k, values := range header {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+	req.Header.Set("User-Agent", fetchUserAgent)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	atomic.AddInt64(&f.requests, 1)
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error on sending http request: %v", err)
+	}
+	defer resp.Body.Close()
+	raw, err := ioutil.ReadAll(resp.Body)
+	atomic.AddInt64(&f.bytes, int64(len(raw)))
+	if err != nil {
+		return nil, fmt.Errorf("error on read from response body: %v", err)
+	}
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, fmt.Errorf("error on decompressing response body: %v", err)
+		}
+		raw, err = ioutil.ReadAll(gz)
+		if err != nil {
+			return nil, fmt.Errorf("error on decompressing response body: %v", err)
+		}
+	}
+	return &FetchResponse{StatusCode: resp.StatusCode, Status: resp.Status, Header: resp.Header, Body: raw}, nil
+}
+
+// backoff returns the jittered duration to wait after the given attempt (base * 2^attempt * [0.5, 1.5))
+func (f *Fetcher) backoff(attempt int) time.Duration {
+	d := f.opts.Backoff << uint(attempt)
+	return time.Duration(float64(d) * (0.5 + rand.Float64()))
+}
+
+// isRateLimited checks if a response has been rejected because of an exceeded (secondary) rate limit
+func isRateLimited(resp *FetchResponse) bool {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	if resp.StatusCode == http.StatusForbidden && (resp.Header.Get("X-RateLimit-Remaining") == "0" || resp.Header.Get("Retry-After") != "") {
+		return true
+	}
+	// the graphql api answers exceeded rate limits with 200 and a RATE_LIMITED error
+	return resp.StatusCode == http.StatusOK && resp.Header.Get("X-RateLimit-Remaining") == "0" && bytes.Contains(resp.Body, []byte(`"RATE_LIMITED"`))
+}
+
+// rateLimitWait returns the duration until the rate limit of a response resets
+func rateLimitWait(resp *FetchResponse, fallback time.Duration) time.Duration {
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		return time.Until(time.Unix(reset, 0))
+	}
+	return fallback
+}
+
+// withRequestTimeout derives a context for a single request, a timeout <= 0 does not limit the request
+func withRequestTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
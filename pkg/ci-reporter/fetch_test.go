@@ -0,0 +1,122 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetcherReleasesSlotWhileBackingOff(t *testing.T) {
+	var unavailable int64
+	firstAttempt := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/unavailable" {
+			if atomic.AddInt64(&unavailable, 1) == 1 {
+				close(firstAttempt)
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// the first retry waits at least 500ms
+	fetcher := NewFetcher(FetcherOptions{Concurrency: 1, Retries: 1, Backoff: time.Second})
+	retried := make(chan error, 1)
+	go func() {
+		_, err := fetcher.Get(context.Background(), server.URL+"/unavailable", nil)
+		retried <- err
+	}()
+	<-firstAttempt
+
+	start := time.Now()
+	resp, err := fetcher.Get(context.Background(), server.URL+"/ok", nil)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Get() = %v, %v, want 200 OK", resp, err)
+	}
+	if waited := time.Since(start); waited > 400*time.Millisecond {
+		t.Errorf("the request waited %s for the slot of the request that backs off", waited)
+	}
+	select {
+	case err := <-retried:
+		t.Errorf("the request that backs off returned before the other one (%v)", err)
+	default:
+	}
+
+	if err := <-retried; err == nil {
+		t.Error("Get() of a 503 returned no error")
+	}
+	if got := atomic.LoadInt64(&unavailable); got != 2 {
+		t.Errorf("got %d attempts, want 2", got)
+	}
+	if stats := fetcher.Stats(); stats.Requests != 3 || stats.Retries != 1 || stats.Failures != 1 {
+		t.Errorf("Stats() = %+v, want 3 requests, 1 retry, 1 failure", stats)
+	}
+}
+
+func TestFetcherCanceledWhileWaitingForSlot(t *testing.T) {
+	fetcher := NewFetcher(FetcherOptions{Concurrency: 1})
+	if err := fetcher.acquire(context.Background()); err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+	defer fetcher.release()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := fetcher.Get(ctx, "http://127.0.0.1:0/", nil); err != context.DeadlineExceeded {
+		t.Errorf("Get() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestFetcherCacheKeyedByToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer server.Close()
+
+	fetcher := NewFetcher(FetcherOptions{CacheDir: t.TempDir(), MaxAge: time.Hour})
+	get := func(authToken string) string {
+		resp, err := fetcher.Get(context.Background(), server.URL, githubHeader(authToken))
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		return string(resp.Body)
+	}
+	for _, tc := range []struct {
+		authToken string
+		want      string
+	}{
+		{authToken: "first", want: "Bearer first"},
+		{authToken: "second", want: "Bearer second"},
+		{authToken: "", want: ""},
+		// served from the cache
+		{authToken: "first", want: "Bearer first"},
+	} {
+		if got := get(tc.authToken); got != tc.want {
+			t.Errorf("Get() with token '%s' = '%s', want '%s'", tc.authToken, got, tc.want)
+		}
+	}
+	if stats := fetcher.Stats(); stats.Requests != 3 || stats.CacheHits != 1 {
+		t.Errorf("Stats() = %+v, want 3 requests, 1 cache hit", stats)
+	}
+}
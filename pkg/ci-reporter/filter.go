@@ -0,0 +1,202 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+)
+
+// How the patterns of a filter rule are matched
+const (
+	// filterMatchExact patterns have to be equal to the label, milestone or login (default)
+	filterMatchExact = "exact"
+	// filterMatchGlob patterns are shell globs like 'lifecycle/*'
+	filterMatchGlob = "glob"
+)
+
+var filterMatchOptions = []string{filterMatchExact, filterMatchGlob}
+
+// GithubFilterRules rules that decide which github issues are part of the report (set via -filter-rules)
+type GithubFilterRules struct {
+	Rules []GithubFilterRule `json:"rules"`
+}
+
+// GithubFilterRule an issue is filtered out by the rule if any of the conditions that are set does not hold
+type GithubFilterRule struct {
+	// Name is printed with the number of issues the rule filtered out
+	Name string `json:"name"`
+	// Match 'exact' or 'glob'
+	Match string `json:"match,omitempty"`
+	// IncludeAny issues need at least one of these labels
+	IncludeAny []string `json:"include_any,omitempty"`
+	// IncludeAll issues need all of these labels
+	IncludeAll []string `json:"include_all,omitempty"`
+	// ExcludeAny issues must not have any of these labels
+	ExcludeAny []string `json:"exclude_any,omitempty"`
+	// Milestones & ExcludeMilestones the milestone of issues has to match / must not match one of these
+	Milestones        []string `json:"milestones,omitempty"`
+	ExcludeMilestones []string `json:"exclude_milestones,omitempty"`
+	// Authors & ExcludeAuthors the author of issues has to match / must not match one of these
+	Authors        []string `json:"authors,omitempty"`
+	ExcludeAuthors []string `json:"exclude_authors,omitempty"`
+	// Assignees & ExcludeAssignees one of the assignees has to match / none of the assignees must match one of these
+	Assignees        []string `json:"assignees,omitempty"`
+	ExcludeAssignees []string `json:"exclude_assignees,omitempty"`
+}
+
+// defaultGithubFilterRules issues that are triaged for later or inactive are not part of the report
+var defaultGithubFilterRules = GithubFilterRules{Rules: []GithubFilterRule{
+	{Name: "backlog", ExcludeAny: []string{"priority/backlog"}},
+	{Name: "triage accepted", ExcludeAny: []string{"triage/accepted"}},
+	{Name: "lifecycle stale or rotten", ExcludeAny: []string{"lifecycle/rotten", "lifecycle/stale"}},
+}}
+
+// LoadGithubFilterRules reads the filter rules from a json file, the default rules are returned if file is empty
+func LoadGithubFilterRules(file string) (GithubFilterRules, error) {
+	if file == "" {
+		return defaultGithubFilterRules, nil
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return GithubFilterRules{}, err
+	}
+	// misspelled conditions (like 'exclude_labels') would silently hold for every issue
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+	var rules GithubFilterRules
+	if err := decoder.Decode(&rules); err != nil {
+		return GithubFilterRules{}, fmt.Errorf("could not unmarshal %s: %v", file, err)
+	}
+	for i, rule := range rules.Rules {
+		if rule.Name == "" {
+			return GithubFilterRules{}, fmt.Errorf("rule %d has no name", i+1)
+		}
+		if rule.Match != "" && !containsString(filterMatchOptions, rule.Match) {
+			return GithubFilterRules{}, fmt.Errorf("match of rule '%s' does not match options [%s]", rule.Name, strings.Join(filterMatchOptions, ", "))
+		}
+		if rule.Match != filterMatchGlob {
+			continue
+		}
+		for _, patterns := range [][]string{rule.IncludeAny, rule.IncludeAll, rule.ExcludeAny, rule.Milestones, rule.ExcludeMilestones, rule.Authors, rule.ExcludeAuthors, rule.Assignees, rule.ExcludeAssignees} {
+			for _, pattern := range patterns {
+				if _, err := path.Match(pattern, ""); err != nil {
+					return GithubFilterRules{}, fmt.Errorf("pattern '%s' of rule '%s' is invalid: %v", pattern, rule.Name, err)
+				}
+			}
+		}
+	}
+	return rules, nil
+}
+
+// Apply returns the issues that pass all rules and the number of issues each rule filtered out, an issue is
+// counted for the first rule that filters it out only
+func (f GithubFilterRules) Apply(issues GithubIssuesAfterID) (GithubIssuesAfterID, map[string]int) {
+	filteredIssues := GithubIssuesAfterID{}
+	filteredOut := map[string]int{}
+	for id, issue := range issues {
+		passed := true
+		for _, rule := range f.Rules {
+			if !rule.passes(issue) {
+				filteredOut[rule.Name]++
+				passed = false
+				break
+			}
+		}
+		if passed {
+			filteredIssues[id] = issue
+		}
+	}
+	return filteredIssues, filteredOut
+}
+
+// passes checks if an issue holds all conditions of the rule
+func (r GithubFilterRule) passes(issue GithubIssueElement) bool {
+	labels := labelNames(issue.Labels)
+	if len(r.IncludeAny) != 0 && !r.matchesAny(r.IncludeAny, labels) {
+		return false
+	}
+	for _, pattern := range r.IncludeAll {
+		if !r.matchesAny([]string{pattern}, labels) {
+			return false
+		}
+	}
+	if r.matchesAny(r.ExcludeAny, labels) {
+		return false
+	}
+	milestone := []string{milestoneTitle(issue.Milestone)}
+	if len(r.Milestones) != 0 && !r.matchesAny(r.Milestones, milestone) {
+		return false
+	}
+	if r.matchesAny(r.ExcludeMilestones, milestone) {
+		return false
+	}
+	author := []string{issue.User.Login}
+	if len(r.Authors) != 0 && !r.matchesAny(r.Authors, author) {
+		return false
+	}
+	if r.matchesAny(r.ExcludeAuthors, author) {
+		return false
+	}
+	assignees := []string{}
+	for _, assignee := range issue.Assignees {
+		assignees = append(assignees, assignee.Login)
+	}
+	if len(r.Assignees) != 0 && !r.matchesAny(r.Assignees, assignees) {
+		return false
+	}
+	return !r.matchesAny(r.ExcludeAssignees, assignees)
+}
+
+// matchesAny checks if any of the values matches any of the patterns
+func (r GithubFilterRule) matchesAny(patterns []string, values []string) bool {
+	for _, pattern := range patterns {
+		for _, value := range values {
+			if value == "" {
+				continue
+			}
+			if r.Match == filterMatchGlob {
+				if matched, _ := path.Match(pattern, value); matched {
+					return true
+				}
+			} else if pattern == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// filteredOutNote summarizes the number of issues filtered out per rule like 'Filtered out: backlog 3, triage
+// accepted 1'
+func filteredOutNote(filteredOut map[string]int) string {
+	rules := []string{}
+	for rule := range filteredOut {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+	counts := []string{}
+	for _, rule := range rules {
+		counts = append(counts, fmt.Sprintf("%s %d", rule, filteredOut[rule]))
+	}
+	return fmt.Sprintf("Filtered out: %s", strings.Join(counts, ", "))
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestLoadGithubFilterRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   string
+		want    GithubFilterRules
+		wantErr string
+	}{
+		{
+			name:  "valid",
+			rules: `{"rules": [{"name": "lifecycle", "match": "glob", "exclude_any": ["lifecycle/*"]}]}`,
+			want:  GithubFilterRules{Rules: []GithubFilterRule{{Name: "lifecycle", Match: filterMatchGlob, ExcludeAny: []string{"lifecycle/*"}}}},
+		},
+		{name: "unknown condition", rules: `{"rules": [{"name": "backlog", "exclude_labels": ["priority/backlog"]}]}`, wantErr: `unknown field "exclude_labels"`},
+		{name: "unknown key", rules: `{"rule": [{"name": "backlog"}]}`, wantErr: `unknown field "rule"`},
+		{name: "no name", rules: `{"rules": [{"exclude_any": ["priority/backlog"]}]}`, wantErr: "rule 1 has no name"},
+		{name: "unknown match", rules: `{"rules": [{"name": "backlog", "match": "regex"}]}`, wantErr: "match of rule 'backlog' does not match options"},
+		{name: "invalid glob", rules: `{"rules": [{"name": "backlog", "match": "glob", "exclude_any": ["priority/[backlog"]}]}`, wantErr: "pattern 'priority/[backlog' of rule 'backlog' is invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "filter-rules.json")
+			if err := ioutil.WriteFile(file, []byte(tt.rules), 0o644); err != nil {
+				t.Fatalf("could not write %s: %v", file, err)
+			}
+			got, err := LoadGithubFilterRules(file)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("LoadGithubFilterRules() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadGithubFilterRules() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadGithubFilterRules() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadGithubFilterRulesExample(t *testing.T) {
+	if _, err := LoadGithubFilterRules(filepath.Join("..", "..", "examples", "filter-rules.json")); err != nil {
+		t.Errorf("LoadGithubFilterRules() error = %v", err)
+	}
+}
+
+// filterTestIssue returns an issue of kubernetes/kubernetes with labels
+func filterTestIssue(number int64, labels ...string) GithubIssueElement {
+	issue := GithubIssueElement{
+		HTMLURL:       fmt.Sprintf("https://github.com/kubernetes/kubernetes/issues/%d", number),
+		RepositoryURL: githubAPIURL + "/repos/kubernetes/kubernetes",
+		Number:        number,
+		User:          GithubUser{Login: "alice"},
+		Assignees:     []GithubUser{{Login: "bob"}, {Login: "carol"}},
+		Milestone:     &Milestone{Title: "v1.23"},
+	}
+	for _, label := range labels {
+		issue.Labels = append(issue.Labels, Label{Name: label})
+	}
+	return issue
+}
+
+func TestGithubFilterRulePasses(t *testing.T) {
+	withoutMilestone := filterTestIssue(4, "kind/flake")
+	withoutMilestone.Milestone = nil
+	unassigned := filterTestIssue(5, "kind/flake")
+	unassigned.Assignees = nil
+
+	tests := []struct {
+		name  string
+		rule  GithubFilterRule
+		issue GithubIssueElement
+		want  bool
+	}{
+		{name: "no conditions", rule: GithubFilterRule{}, issue: filterTestIssue(1), want: true},
+		{name: "include any matches", rule: GithubFilterRule{IncludeAny: []string{"sig/node", "sig/network"}}, issue: filterTestIssue(1, "sig/network"), want: true},
+		{name: "include any does not match", rule: GithubFilterRule{IncludeAny: []string{"sig/node", "sig/network"}}, issue: filterTestIssue(1, "sig/storage"), want: false},
+		{name: "include any without labels", rule: GithubFilterRule{IncludeAny: []string{"sig/node"}}, issue: filterTestIssue(1), want: false},
+		{name: "include all matches", rule: GithubFilterRule{IncludeAll: []string{"kind/flake", "sig/node"}}, issue: filterTestIssue(1, "sig/node", "kind/flake", "priority/important-soon"), want: true},
+		{name: "include all misses one", rule: GithubFilterRule{IncludeAll: []string{"kind/flake", "sig/node"}}, issue: filterTestIssue(1, "kind/flake"), want: false},
+		{name: "exclude any matches", rule: GithubFilterRule{ExcludeAny: []string{"priority/backlog"}}, issue: filterTestIssue(1, "priority/backlog"), want: false},
+		{name: "exclude any does not match", rule: GithubFilterRule{ExcludeAny: []string{"priority/backlog"}}, issue: filterTestIssue(1, "priority/important-soon"), want: true},
+		// exact patterns have to be equal to the label
+		{name: "exact is not a prefix", rule: GithubFilterRule{ExcludeAny: []string{"priority/backlog"}}, issue: filterTestIssue(1, "priority/backlog-x"), want: true},
+		{name: "exact does not expand globs", rule: GithubFilterRule{ExcludeAny: []string{"lifecycle/*"}}, issue: filterTestIssue(1, "lifecycle/stale"), want: true},
+		{name: "exact matches a glob literally", rule: GithubFilterRule{ExcludeAny: []string{"lifecycle/*"}}, issue: filterTestIssue(1, "lifecycle/*"), want: false},
+		{name: "glob matches", rule: GithubFilterRule{Match: filterMatchGlob, ExcludeAny: []string{"lifecycle/*"}}, issue: filterTestIssue(1, "lifecycle/rotten"), want: false},
+		{name: "glob does not match", rule: GithubFilterRule{Match: filterMatchGlob, ExcludeAny: []string{"lifecycle/*"}}, issue: filterTestIssue(1, "kind/flake"), want: true},
+		{name: "glob is anchored", rule: GithubFilterRule{Match: filterMatchGlob, ExcludeAny: []string{"priority/backlog"}}, issue: filterTestIssue(1, "priority/backlog-x"), want: true},
+		{name: "glob include all", rule: GithubFilterRule{Match: filterMatchGlob, IncludeAll: []string{"sig/*", "kind/*"}}, issue: filterTestIssue(1, "sig/node", "kind/flake"), want: true},
+		{name: "milestone matches", rule: GithubFilterRule{Milestones: []string{"v1.23"}}, issue: filterTestIssue(1), want: true},
+		{name: "milestone does not match", rule: GithubFilterRule{Milestones: []string{"v1.24"}}, issue: filterTestIssue(1), want: false},
+		{name: "milestone glob", rule: GithubFilterRule{Match: filterMatchGlob, Milestones: []string{"v1.*"}}, issue: filterTestIssue(1), want: true},
+		{name: "no milestone does not match milestones", rule: GithubFilterRule{Milestones: []string{"v1.23"}}, issue: withoutMilestone, want: false},
+		{name: "no milestone passes exclude milestones", rule: GithubFilterRule{ExcludeMilestones: []string{"v1.23"}}, issue: withoutMilestone, want: true},
+		{name: "exclude milestone", rule: GithubFilterRule{ExcludeMilestones: []string{"v1.23"}}, issue: filterTestIssue(1), want: false},
+		{name: "author matches", rule: GithubFilterRule{Authors: []string{"alice"}}, issue: filterTestIssue(1), want: true},
+		{name: "author does not match", rule: GithubFilterRule{Authors: []string{"bob"}}, issue: filterTestIssue(1), want: false},
+		{name: "exclude author", rule: GithubFilterRule{ExcludeAuthors: []string{"alice"}}, issue: filterTestIssue(1), want: false},
+		{name: "exclude bot authors", rule: GithubFilterRule{Match: filterMatchGlob, ExcludeAuthors: []string{"*[bot]"}}, issue: filterTestIssue(1), want: true},
+		{name: "one assignee matches", rule: GithubFilterRule{Assignees: []string{"carol"}}, issue: filterTestIssue(1), want: true},
+		{name: "no assignee matches", rule: GithubFilterRule{Assignees: []string{"dave"}}, issue: filterTestIssue(1), want: false},
+		{name: "unassigned does not match assignees", rule: GithubFilterRule{Assignees: []string{"bob"}}, issue: unassigned, want: false},
+		{name: "exclude assignee", rule: GithubFilterRule{ExcludeAssignees: []string{"carol"}}, issue: filterTestIssue(1), want: false},
+		{name: "unassigned passes exclude assignees", rule: GithubFilterRule{ExcludeAssignees: []string{"carol"}}, issue: unassigned, want: true},
+		{name: "all conditions hold", rule: GithubFilterRule{IncludeAny: []string{"kind/flake"}, ExcludeAny: []string{"priority/backlog"}, Milestones: []string{"v1.23"}, Authors: []string{"alice"}, Assignees: []string{"bob"}}, issue: filterTestIssue(1, "kind/flake"), want: true},
+		{name: "one condition does not hold", rule: GithubFilterRule{IncludeAny: []string{"kind/flake"}, ExcludeAny: []string{"priority/backlog"}, Milestones: []string{"v1.23"}, Authors: []string{"alice"}, Assignees: []string{"dave"}}, issue: filterTestIssue(1, "kind/flake"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.passes(tt.issue); got != tt.want {
+				t.Errorf("passes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGithubFilterRulesApply(t *testing.T) {
+	issues := GithubIssuesAfterID{}
+	for _, issue := range []GithubIssueElement{
+		filterTestIssue(1, "kind/flake"),
+		filterTestIssue(2, "kind/flake", "priority/backlog"),
+		// filtered out by both rules, counted for the first one only
+		filterTestIssue(3, "kind/flake", "priority/backlog", "lifecycle/stale"),
+		filterTestIssue(4, "kind/failing-test", "lifecycle/rotten"),
+		filterTestIssue(5, "kind/flake", "priority/backlog-x"),
+	} {
+		issues[issue.Key()] = issue
+	}
+	rules := GithubFilterRules{Rules: []GithubFilterRule{
+		{Name: "backlog", ExcludeAny: []string{"priority/backlog"}},
+		{Name: "lifecycle", Match: filterMatchGlob, ExcludeAny: []string{"lifecycle/*"}},
+		{Name: "unused", ExcludeAuthors: []string{"dave"}},
+	}}
+
+	got, filteredOut := rules.Apply(issues)
+	gotKeys := []string{}
+	for key := range got {
+		gotKeys = append(gotKeys, key)
+	}
+	sort.Strings(gotKeys)
+	if want := []string{"kubernetes/kubernetes#1", "kubernetes/kubernetes#5"}; !reflect.DeepEqual(gotKeys, want) {
+		t.Errorf("Apply() issues = %v, want %v", gotKeys, want)
+	}
+	// rules that filtered out no issue are not counted
+	if want := map[string]int{"backlog": 2, "lifecycle": 1}; !reflect.DeepEqual(filteredOut, want) {
+		t.Errorf("Apply() filtered out = %v, want %v", filteredOut, want)
+	}
+	if got, want := filteredOutNote(filteredOut), "Filtered out: backlog 2, lifecycle 1"; got != want {
+		t.Errorf("filteredOutNote() = %q, want %q", got, want)
+	}
+
+	// the default rules filter out triaged and inactive issues
+	_, filteredOut = defaultGithubFilterRules.Apply(issues)
+	if want := map[string]int{"backlog": 2, "lifecycle stale or rotten": 1}; !reflect.DeepEqual(filteredOut, want) {
+		t.Errorf("Apply() of the default rules filtered out = %v, want %v", filteredOut, want)
+	}
+}
+
+func TestFilterGithubIssues(t *testing.T) {
+	issue := filterTestIssue(1)
+	pullRequest := filterTestIssue(2)
+	pullRequest.HTMLURL = "https://github.com/kubernetes/kubernetes/pull/2"
+	// 'pull' in the repository name does not make an issue a pull request
+	pullRepoIssue := filterTestIssue(3)
+	pullRepoIssue.HTMLURL = "https://github.com/kubernetes-sigs/pull-tools/issues/3"
+	pullRepoIssue.RepositoryURL = githubAPIURL + "/repos/kubernetes-sigs/pull-tools"
+
+	got := filterGithubIssues(GithubIssues{issue, pullRequest, pullRepoIssue})
+	want := GithubIssuesAfterID{issue.Key(): issue, pullRepoIssue.Key(): pullRepoIssue}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("filterGithubIssues() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,345 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// APIs the github issues can be requested with (set via -github-api)
+const (
+	// githubAPIREST requests the issues, their last comment and their timeline with one request each (default)
+	githubAPIREST = "rest"
+	// githubAPIGraphQL requests the issues with everything the report needs in a few paged queries
+	githubAPIGraphQL = "graphql"
+)
+
+var githubAPIOptions = []string{githubAPIREST, githubAPIGraphQL}
+
+// graphQLPerPage number of issues requested per query
+const graphQLPerPage = 50
+
+// githubIssuesQuery searches issues with the fields the report needs. Labels, assignees, project cards and cross
+// references are limited to the first 100 (assignees and project cards 20), which is far more than failing-test and
+// flake issues have. The ci status is the combined status of the head commit like the rest api returns it.
+const githubIssuesQuery = `query($query: String!, $first: Int!, $after: String) {
+  search(query: $query, type: ISSUE, first: $first, after: $after) {
+    issueCount
+    pageInfo { hasNextPage endCursor }
+    nodes {
+      ... on Issue {
+        number
+        title
+        url
+        state
+        createdAt
+        updatedAt
+        closedAt
+        author { login __typename }
+        repository { nameWithOwner }
+        milestone { title }
+        labels(first: 100) { nodes { name color } }
+        assignees(first: 20) { nodes { login } }
+        projectCards(first: 20) { nodes { column { name } project { databaseId } } }
+        comments(last: 1) { totalCount nodes { createdAt author { login __typename } } }
+        timelineItems(first: 100, itemTypes: [CROSS_REFERENCED_EVENT]) {
+          nodes {
+            ... on CrossReferencedEvent {
+              source {
+                ... on PullRequest {
+                  number
+                  title
+                  url
+                  state
+                  commits(last: 1) { nodes { commit { status { state } } } }
+                }
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+// graphQLRequest body of a github graphql request
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+// graphQLIssuesResponse response of githubIssuesQuery
+type graphQLIssuesResponse struct {
+	Data struct {
+		Search struct {
+			IssueCount int `json:"issueCount"`
+			PageInfo   struct {
+				HasNextPage bool   `json:"hasNextPage"`
+				EndCursor   string `json:"endCursor"`
+			} `json:"pageInfo"`
+			Nodes []graphQLIssue `json:"nodes"`
+		} `json:"search"`
+	} `json:"data"`
+	Errors []struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// graphQLActor author of an issue or comment
+type graphQLActor struct {
+	Login    string `json:"login"`
+	Typename string `json:"__typename"`
+}
+
+// graphQLIssue issue of githubIssuesQuery
+type graphQLIssue struct {
+	Number     int64         `json:"number"`
+	Title      string        `json:"title"`
+	URL        string        `json:"url"`
+	State      string        `json:"state"`
+	CreatedAt  string        `json:"createdAt"`
+	UpdatedAt  string        `json:"updatedAt"`
+	ClosedAt   string        `json:"closedAt"`
+	Author     *graphQLActor `json:"author"`
+	Repository struct {
+		NameWithOwner string `json:"nameWithOwner"`
+	} `json:"repository"`
+	Milestone *Milestone `json:"milestone"`
+	Labels    struct {
+		Nodes []Label `json:"nodes"`
+	} `json:"labels"`
+	Assignees struct {
+		Nodes []graphQLActor `json:"nodes"`
+	} `json:"assignees"`
+	ProjectCards struct {
+		Nodes []struct {
+			Column *struct {
+				Name string `json:"name"`
+			} `json:"column"`
+			Project struct {
+				DatabaseID int64 `json:"databaseId"`
+			} `json:"project"`
+		} `json:"nodes"`
+	} `json:"projectCards"`
+	Comments struct {
+		TotalCount int64 `json:"totalCount"`
+		Nodes      []struct {
+			CreatedAt string        `json:"createdAt"`
+			Author    *graphQLActor `json:"author"`
+		} `json:"nodes"`
+	} `json:"comments"`
+	TimelineItems struct {
+		Nodes []struct {
+			Source *struct {
+				Number  int64  `json:"number"`
+				Title   string `json:"title"`
+				URL     string `json:"url"`
+				State   string `json:"state"`
+				Commits struct {
+					Nodes []struct {
+						Commit struct {
+							// Status is null if the commit has no status
+							Status *struct {
+								State string `json:"state"`
+							} `json:"status"`
+						} `json:"commit"`
+					} `json:"nodes"`
+				} `json:"commits"`
+			} `json:"source"`
+		} `json:"nodes"`
+	} `json:"timelineItems"`
+}
+
+// QueryGithubIssues requests the issues of every cfg with the github graphql api. The issues, their last comments,
+// the pull requests that reference them and their project columns are the same as requested through the rest api,
+// the data that has been collected until an error occurred is returned with the error.
+func QueryGithubIssues(ctx context.Context, fetcher *Fetcher, requestCfg []GithubIssueRequest) (GithubIssuesData, error) {
+	data := NewGithubIssuesData()
+	errorMessages := []string{}
+	var mu sync.Mutex
+	wg := sync.WaitGroup{}
+	for _, cfg := range requestCfg {
+		wg.Add(1)
+		go func(cfg GithubIssueRequest) {
+			defer wg.Done()
+			nodes, err := queryGithubIssues(ctx, fetcher, cfg)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errorMessages = append(errorMessages, fmt.Sprintf("could not request %s issues of %s: %v", cfg.Params[IssueReqParamLabels], cfg.source(), err))
+			}
+			for _, node := range nodes {
+				issue, lastComment, linked, columns := node.normalize()
+				data.AddIssue(issue, cfg.Params[IssueReqParamLabels])
+				if lastComment != nil {
+					data.LastComments[issue.Key()] = *lastComment
+				}
+				data.PullRequests[issue.Key()] = linked
+				if len(columns) != 0 {
+					data.ProjectColumns[issue.Key()] = columns
+				}
+			}
+		}(cfg)
+	}
+	wg.Wait()
+	if len(errorMessages) != 0 {
+		sort.Strings(errorMessages)
+		return data, fmt.Errorf("%s", strings.Join(errorMessages, ", "))
+	}
+	return data, nil
+}
+
+// queryGithubIssues pages through the search results of a single cfg
+func queryGithubIssues(ctx context.Context, fetcher *Fetcher, cfg GithubIssueRequest) ([]graphQLIssue, error) {
+	if cfg.Search == "" {
+		cfg.Search = fmt.Sprintf("repo:%s/%s", cfg.Owner, cfg.Repo)
+	}
+	query := githubSearchQuery(cfg)
+	nodes := []graphQLIssue{}
+	variables := map[string]interface{}{"query": query, "first": graphQLPerPage}
+	for {
+		var resp graphQLIssuesResponse
+		if err := reqGithubGraphQL(ctx, fetcher, graphQLRequest{Query: githubIssuesQuery, Variables: variables}, cfg.AuthToken, &resp); err != nil {
+			return nodes, err
+		}
+		for _, node := range resp.Data.Search.Nodes {
+			// nodes that are no issues are empty
+			if node.Number != 0 {
+				nodes = append(nodes, node)
+			}
+		}
+		pageInfo := resp.Data.Search.PageInfo
+		if !pageInfo.HasNextPage {
+			return nodes, nil
+		}
+		if len(nodes) >= searchMaxResults {
+			return nodes, fmt.Errorf("the search '%s' has more than %d results, narrow it down", query, searchMaxResults)
+		}
+		variables["after"] = pageInfo.EndCursor
+	}
+}
+
+// reqGithubGraphQL sends a graphql request and unmarshals the response into v, errors of the response are returned
+func reqGithubGraphQL(ctx context.Context, fetcher *Fetcher, request graphQLRequest, authToken string, v *graphQLIssuesResponse) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	header := githubHeader(authToken)
+	header.Set("Content-Type", "application/json")
+	url := githubAPIURL + "/graphql"
+	resp, err := fetcher.Do(ctx, http.MethodPost, url, header, body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	if err := json.Unmarshal(resp.Body, v); err != nil {
+		return fmt.Errorf("could not unmarshal %s: %v", url, err)
+	}
+	if len(v.Errors) != 0 {
+		return fmt.Errorf("%s returned %s", url, v.Errors[0].Message)
+	}
+	return nil
+}
+
+// normalize converts an issue of the graphql api into the issue, last comment, linked pull requests and project
+// columns the rest api returns
+func (n graphQLIssue) normalize() (GithubIssueElement, *GithubComment, []LinkedPullRequest, []string) {
+	repositoryURL := fmt.Sprintf("%s/repos/%s", githubAPIURL, n.Repository.NameWithOwner)
+	issue := GithubIssueElement{
+		HTMLURL:       n.URL,
+		RepositoryURL: repositoryURL,
+		CommentsURL:   fmt.Sprintf("%s/issues/%d/comments", repositoryURL, n.Number),
+		TimelineURL:   fmt.Sprintf("%s/issues/%d/timeline", repositoryURL, n.Number),
+		User:          n.Author.githubUser(),
+		Number:        n.Number,
+		Title:         n.Title,
+		Labels:        n.Labels.Nodes,
+		State:         strings.ToLower(n.State),
+		Assignees:     []GithubUser{},
+		Milestone:     n.Milestone,
+		Comments:      n.Comments.TotalCount,
+		CreatedAt:     n.CreatedAt,
+		UpdatedAt:     n.UpdatedAt,
+		ClosedAt:      n.ClosedAt,
+	}
+	for _, assignee := range n.Assignees.Nodes {
+		issue.Assignees = append(issue.Assignees, GithubUser{Login: assignee.Login, Type: "User"})
+	}
+
+	var lastComment *GithubComment
+	if len(n.Comments.Nodes) != 0 {
+		comment := n.Comments.Nodes[len(n.Comments.Nodes)-1]
+		lastComment = &GithubComment{User: comment.Author.githubUser(), CreatedAt: comment.CreatedAt}
+	}
+
+	// pull requests are deduplicated and sorted like reqIssueTimeline does
+	linked := map[string]LinkedPullRequest{}
+	for _, node := range n.TimelineItems.Nodes {
+		// sources that are no pull requests are empty
+		if node.Source == nil || node.Source.URL == "" {
+			continue
+		}
+		pr := LinkedPullRequest{Number: node.Source.Number, URL: node.Source.URL, Title: node.Source.Title, State: strings.ToLower(node.Source.State)}
+		if commits := node.Source.Commits.Nodes; pr.State == pullRequestOpen && len(commits) != 0 {
+			// like the combined status of the rest api a commit without status is pending
+			state := ""
+			if status := commits[0].Commit.Status; status != nil {
+				state = status.State
+			}
+			pr.CIStatus = combinedStatusState(state)
+		}
+		linked[pr.URL] = pr
+	}
+	pullRequests := []LinkedPullRequest{}
+	for _, pr := range linked {
+		pullRequests = append(pullRequests, pr)
+	}
+	sort.Slice(pullRequests, func(i, j int) bool {
+		return pullRequests[i].URL < pullRequests[j].URL
+	})
+
+	columnByProject := map[int64]string{}
+	for _, card := range n.ProjectCards.Nodes {
+		// cards that have not been triaged into a column yet have none
+		if card.Column != nil {
+			columnByProject[card.Project.DatabaseID] = card.Column.Name
+		}
+	}
+	return issue, lastComment, pullRequests, projectColumnNames(columnByProject)
+}
+
+// githubUser converts a graphql actor into the user the rest api returns, the login of bots ends with '[bot]' there
+// (deleted accounts have no actor)
+func (a *graphQLActor) githubUser() GithubUser {
+	if a == nil {
+		return GithubUser{Login: "ghost", Type: "User"}
+	}
+	if a.Typename == "Bot" {
+		return GithubUser{Login: a.Login + "[bot]", Type: "Bot"}
+	}
+	return GithubUser{Login: a.Login, Type: "User"}
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// restResponses comments, timelines, pull requests and combined statuses of the issues in testdata/github
+var restResponses = map[string]string{
+	"/repos/kubernetes/kubernetes/issues/105965/comments": `[{"user": {"login": "k8s-triage-robot", "type": "User"}, "created_at": "2021-10-28T10:00:00Z"}]`,
+	"/repos/kubernetes/kubernetes/issues/106139/comments": `[{"user": {"login": "pacoxu", "type": "User"}, "created_at": "2021-11-02T09:00:00Z"}]`,
+	"/repos/kubernetes/kubernetes/issues/105965/timeline": `[
+		{"event": "added_to_project", "project_card": {"project_id": 10, "column_name": "Triage"}},
+		{"event": "cross-referenced", "source": {"issue": {"number": 105970, "title": "Fix volume metrics", "state": "open", "html_url": "https://github.com/kubernetes/kubernetes/pull/105970", "pull_request": {"url": "https://api.github.com/repos/kubernetes/kubernetes/pulls/105970", "merged_at": null}}}},
+		{"event": "cross-referenced", "source": {"issue": {"number": 105971, "title": "Related issue", "state": "open", "html_url": "https://github.com/kubernetes/kubernetes/issues/105971"}}},
+		{"event": "moved_columns_in_project", "project_card": {"project_id": 10, "column_name": "In Progress", "previous_column_name": "Triage"}},
+		{"event": "cross-referenced", "source": {"issue": {"number": 105980, "title": "Skip volume metrics", "state": "closed", "html_url": "https://github.com/kubernetes/kubernetes/pull/105980", "pull_request": {"url": "https://api.github.com/repos/kubernetes/kubernetes/pulls/105980", "merged_at": "2021-10-27T12:00:00Z"}}}}
+	]`,
+	"/repos/kubernetes/kubernetes/issues/106139/timeline": `[
+		{"event": "cross-referenced", "source": {"issue": {"number": 106140, "title": "Increase the ephemeral volume timeout", "state": "open", "html_url": "https://github.com/kubernetes/kubernetes/pull/106140", "pull_request": {"url": "https://api.github.com/repos/kubernetes/kubernetes/pulls/106140", "merged_at": null}}}}
+	]`,
+	"/repos/kubernetes/kubernetes/issues/105242/timeline": `[
+		{"event": "added_to_project", "project_card": {"project_id": 11, "column_name": "Backlog"}},
+		{"event": "cross-referenced", "source": {"issue": {"number": 105250, "title": "Recover the panic", "state": "closed", "html_url": "https://github.com/kubernetes/kubernetes/pull/105250", "pull_request": {"url": "https://api.github.com/repos/kubernetes/kubernetes/pulls/105250", "merged_at": null}}}},
+		{"event": "cross-referenced", "source": {"issue": {"number": 105300, "title": "Wait for the handler", "state": "open", "html_url": "https://github.com/kubernetes/kubernetes/pull/105300", "pull_request": {"url": "https://api.github.com/repos/kubernetes/kubernetes/pulls/105300", "merged_at": null}}}}
+	]`,
+	"/repos/kubernetes/kubernetes/pulls/105970": `{"head": {"sha": "aaa"}, "base": {"repo": {"url": "https://api.github.com/repos/kubernetes/kubernetes"}}}`,
+	"/repos/kubernetes/kubernetes/pulls/106140": `{"head": {"sha": "bbb"}, "base": {"repo": {"url": "https://api.github.com/repos/kubernetes/kubernetes"}}}`,
+	"/repos/kubernetes/kubernetes/pulls/105300": `{"head": {"sha": "ccc"}, "base": {"repo": {"url": "https://api.github.com/repos/kubernetes/kubernetes"}}}`,
+	// a commit without statuses is pending
+	"/repos/kubernetes/kubernetes/commits/aaa/status": `{"state": "pending", "statuses": []}`,
+	"/repos/kubernetes/kubernetes/commits/bbb/status": `{"state": "success", "statuses": [{"state": "success", "context": "pull-kubernetes-e2e-gce"}]}`,
+	"/repos/kubernetes/kubernetes/commits/ccc/status": `{"state": "failure", "statuses": [{"state": "failure", "context": "pull-kubernetes-unit"}]}`,
+}
+
+// githubAPIHandlers serve the issues in testdata/github through the issues and the graphql api, the other rest
+// requests are answered with restResponses
+func githubAPIHandlers(t *testing.T) map[string]http.HandlerFunc {
+	fixture := func(w http.ResponseWriter, name string) {
+		b, err := ioutil.ReadFile(filepath.Join("testdata", "github", name))
+		if err != nil {
+			t.Errorf("could not read fixture %s: %v", name, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(b)
+	}
+	return map[string]http.HandlerFunc{
+		"/repos/kubernetes/kubernetes/issues": func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("page") != "1" {
+				_, _ = w.Write([]byte("[]"))
+				return
+			}
+			fixture(w, "rest-issues-"+strings.TrimPrefix(r.URL.Query().Get("labels"), "kind/")+".json")
+		},
+		"/graphql": func(w http.ResponseWriter, r *http.Request) {
+			var request graphQLRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			query, _ := request.Variables["query"].(string)
+			if strings.Contains(query, githubLabelFlake) {
+				fixture(w, "graphql-flake.json")
+			} else {
+				fixture(w, "graphql-failing-test.json")
+			}
+		},
+	}
+}
+
+// requestGithubReport requests the github report from the stand-in with the api set via -github-api
+func requestGithubReport(t *testing.T, server *httptest.Server, githubAPI string) ReportData {
+	t.Helper()
+	fetcher := newGithubStandInFetcher(t, server)
+	meta := Meta{
+		Env:                metaEnv{GithubToken: "token"},
+		Flags:              metaFlags{EmojisOff: true, SortBy: sortBySeverity, AttentionDays: 14, Repos: []string{defaultGithubRepo}, GithubAPI: githubAPI},
+		Fetcher:            fetcher,
+		DataPostProcessing: dataPostProcessing,
+	}
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	reportData := (&GithubReport{}).RequestData(context.Background(), meta, &wg)
+	for _, field := range reportData.Data {
+		if field.Error != "" {
+			t.Fatalf("%s: could not request the issues: %s", githubAPI, field.Error)
+		}
+		// the api url of pull requests is only known to the rest api and is not part of the report
+		for _, record := range field.Records {
+			for i := range record.PullRequests {
+				record.PullRequests[i].apiURL = ""
+			}
+		}
+	}
+	return reportData
+}
+
+func TestGithubAPIEquivalence(t *testing.T) {
+	server := newGithubStandIn(t, restResponses, githubAPIHandlers(t))
+	rest := requestGithubReport(t, server, githubAPIREST)
+	graphql := requestGithubReport(t, server, githubAPIGraphQL)
+	if !reflect.DeepEqual(rest, graphql) {
+		restJSON, _ := json.MarshalIndent(rest, "", "  ")
+		graphqlJSON, _ := json.MarshalIndent(graphql, "", "  ")
+		t.Fatalf("the reports differ\nrest:\n%s\ngraphql:\n%s", restJSON, graphqlJSON)
+	}
+
+	// make sure the fixtures are not compared empty
+	if len(rest.Data) != 1 || len(rest.Data[0].Records) != 3 {
+		t.Fatalf("got %+v, want 3 issues", rest.Data)
+	}
+	records := map[int64]ReportDataRecord{}
+	for _, record := range rest.Data[0].Records {
+		records[record.ID] = record
+	}
+	wantPullRequests := map[int64][]LinkedPullRequest{
+		105965: {
+			{Number: 105970, URL: "https://github.com/kubernetes/kubernetes/pull/105970", Title: "Fix volume metrics", State: pullRequestOpen, CIStatus: "pending"},
+			{Number: 105980, URL: "https://github.com/kubernetes/kubernetes/pull/105980", Title: "Skip volume metrics", State: pullRequestMerged},
+		},
+		106139: {
+			{Number: 106140, URL: "https://github.com/kubernetes/kubernetes/pull/106140", Title: "Increase the ephemeral volume timeout", State: pullRequestOpen, CIStatus: "success"},
+		},
+		105242: {
+			{Number: 105250, URL: "https://github.com/kubernetes/kubernetes/pull/105250", Title: "Recover the panic", State: pullRequestClosed},
+			{Number: 105300, URL: "https://github.com/kubernetes/kubernetes/pull/105300", Title: "Wait for the handler", State: pullRequestOpen, CIStatus: "failure"},
+		},
+	}
+	wantColumns := map[int64][]string{105965: {"In Progress"}, 105242: {"Backlog"}}
+	wantMatchedBy := map[int64][]string{105965: {githubLabelFailingTest}, 106139: {githubLabelFailingTest, githubLabelFlake}, 105242: {githubLabelFlake}}
+	for id, record := range records {
+		if !reflect.DeepEqual(record.PullRequests, wantPullRequests[id]) {
+			t.Errorf("pull requests of #%d = %+v, want %+v", id, record.PullRequests, wantPullRequests[id])
+		}
+		if !reflect.DeepEqual(record.ProjectColumns, wantColumns[id]) {
+			t.Errorf("project columns of #%d = %q, want %q", id, record.ProjectColumns, wantColumns[id])
+		}
+		if !reflect.DeepEqual(record.MatchedBy, wantMatchedBy[id]) {
+			t.Errorf("matched by of #%d = %q, want %q", id, record.MatchedBy, wantMatchedBy[id])
+		}
+	}
+	if got := records[105965]; got.LastCommentBy != "k8s-triage-robot" || !got.LastCommentByBot || got.Milestone != "v1.23" {
+		t.Errorf("#105965 last comment by %q (bot %v), milestone %q, want k8s-triage-robot (bot), v1.23", got.LastCommentBy, got.LastCommentByBot, got.Milestone)
+	}
+	if got := records[105242].Assignees; !reflect.DeepEqual(got, []string{"MikeSpreitzer", "tkashem"}) {
+		t.Errorf("assignees of #105242 = %q, want MikeSpreitzer, tkashem", got)
+	}
+}
+
+func TestCombinedStatusState(t *testing.T) {
+	for state, want := range map[string]string{"SUCCESS": "success", "success": "success", "PENDING": "pending", "EXPECTED": "pending", "": "pending", "FAILURE": "failure", "ERROR": "failure"} {
+		if got := combinedStatusState(state); got != want {
+			t.Errorf("combinedStatusState(%q) = %q, want %q", state, got, want)
+		}
+	}
+}
@@ -17,68 +17,150 @@ limitations under the License.
 package cireporter
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"net/http"
-	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 )
 
+func init() {
+	RegisterReporter(githubReport, "open failing-test and flake issues of the repositories set via -repos and -search", func() CIReport { return &GithubReport{} })
+}
+
 // GithubReport used to implement RequestData & Print for github report data
 type GithubReport struct {
 	ReportData ReportData
 }
 
 // RequestData this function is used to get github report data
-func (r *GithubReport) RequestData(meta Meta, wg *sync.WaitGroup) ReportData {
+func (r *GithubReport) RequestData(ctx context.Context, meta Meta, wg *sync.WaitGroup) ReportData {
 	// labels=kind/failing-test&since=2021-09-01&sort=updated&per_page=100&page=1
-	requestCfg := []GithubIssueRequest{
-		{
-			Owner:     "kubernetes",
-			Repo:      "kubernetes",
-			Params:    GithubIssueRequestParameters{IssueReqParamLabels: "kind/failing-test", IssueReqParamSince: "2021-09-01", IssueReqParamSort: "updated", IssueReqParamPerpage: "20"},
-			AuthToken: meta.Env.GithubToken,
-		},
-		{
-			Owner:     "kubernetes",
-			Repo:      "kubernetes",
-			Params:    GithubIssueRequestParameters{IssueReqParamLabels: "kind/flake", IssueReqParamSince: "2021-09-01", IssueReqParamSort: "updated", IssueReqParamPerpage: "20"},
-			AuthToken: meta.Env.GithubToken,
-		},
-	}
-	// request github issue data
-	allReqGithubIssues := GithubIssuesAfterID{}
+	requestCfg := []GithubIssueRequest{}
+	for _, label := range githubIssueLabels {
+		params := GithubIssueRequestParameters{IssueReqParamLabels: label, IssueReqParamSince: githubIssuesSince, IssueReqParamSort: "updated", IssueReqParamPerpage: "20"}
+		for _, repo := range meta.Flags.Repos {
+			ownerRepo := strings.SplitN(repo, "/", 2)
+			requestCfg = append(requestCfg, GithubIssueRequest{Owner: ownerRepo[0], Repo: ownerRepo[1], Params: params, AuthToken: meta.Env.GithubToken})
+		}
+		if meta.Flags.Search != "" {
+			requestCfg = append(requestCfg, GithubIssueRequest{Search: meta.Flags.Search, Params: params, AuthToken: meta.Env.GithubToken})
+		}
+	}
+	// the graphql api returns the issues with their last comment and linked pull requests
+	if meta.Flags.GithubAPI == githubAPIGraphQL {
+		reqError := ""
+		data, err := QueryGithubIssues(ctx, meta.Fetcher, requestCfg)
+		if err != nil {
+			reqError = err.Error()
+		}
+		var filteredOut map[string]int
+		data.Issues, filteredOut = meta.Flags.FilterRules.Apply(data.Issues)
+		return meta.DataPostProcessing(r, githubReport, transformIntoReportData(meta, data, filteredOut, reqError), wg)
+	}
+	// request github issue data, issues found by several queries are merged
+	data := NewGithubIssuesData()
+	reqErrors := make(chan error, len(requestCfg))
+	var mu sync.Mutex
 	var internalWg sync.WaitGroup
 	for _, cfg := range requestCfg {
 		internalWg.Add(1)
 		go func(cfg GithubIssueRequest) {
-			githubIssues := GetGithubIssues(cfg)
-			for k, v := range githubIssues {
-				allReqGithubIssues[k] = v
+			defer internalWg.Done()
+			githubIssues, err := GetGithubIssues(ctx, meta.Fetcher, cfg)
+			if err != nil {
+				reqErrors <- fmt.Errorf("could not request %s issues of %s: %v", cfg.Params[IssueReqParamLabels], cfg.source(), err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, issue := range githubIssues {
+				data.AddIssue(issue, cfg.Params[IssueReqParamLabels])
 			}
-			internalWg.Done()
 		}(cfg)
 	}
 	internalWg.Wait()
+	close(reqErrors)
+	reqErrorMessages := []string{}
+	for err := range reqErrors {
+		reqErrorMessages = append(reqErrorMessages, err.Error())
+	}
+	var filteredOut map[string]int
+	data.Issues, filteredOut = meta.Flags.FilterRules.Apply(data.Issues)
+	// the last comment tells if somebody is working on an issue
+	var err error
+	data.LastComments, err = requestLastComments(ctx, meta.Fetcher, data.Issues, meta.Env.GithubToken)
+	if err != nil {
+		reqErrorMessages = append(reqErrorMessages, err.Error())
+	}
+	// pull requests that reference an issue tell if somebody is fixing it, the project columns how far the triage is
+	data.PullRequests, data.ProjectColumns, err = requestIssueTimelines(ctx, meta.Fetcher, data.Issues, meta.Env.GithubToken)
+	if err != nil {
+		reqErrorMessages = append(reqErrorMessages, err.Error())
+	}
 	// DataPostProcessing collects data requested via assembleGithubRequests/2 and returns ReportData
-	return meta.DataPostProcessing(r, githubReport, transformIntoReportData(meta, allReqGithubIssues), wg)
+	return meta.DataPostProcessing(r, githubReport, transformIntoReportData(meta, data, filteredOut, strings.Join(reqErrorMessages, ", ")), wg)
 }
 
 // Print extends GithubReport and prints report data to the console
 func (r GithubReport) Print(meta Meta, reportData ReportData) {
 	fmt.Print("\n\n")
+	attention := []ReportDataRecord{}
 	for _, data := range reportData.Data {
-		for _, records := range data.Records {
-			fmt.Printf("#%d %s %s\n", records.ID, records.Title, records.Sig)
-			if !meta.Flags.ShortOn {
-				fmt.Printf("- %s\n", records.URL)
+		if data.Error != "" {
+			fmt.Printf("Issues are incomplete: %s\n\n", data.Error)
+		}
+		if len(data.FilteredOut) != 0 {
+			fmt.Printf("%s\n\n", filteredOutNote(data.FilteredOut))
+		}
+		sections := SplitIssues(data.Records)
+		for i, section := range []struct {
+			title   string
+			records []ReportDataRecord
+		}{{"FAILING TESTS:", sections.FailingTests}, {"FLAKES:", sections.Flakes}} {
+			if len(section.records) == 0 {
+				continue
+			}
+			if i != 0 {
+				fmt.Println()
 			}
-			for _, note := range records.Notes {
-				fmt.Printf("- %s\n", note)
+			fmt.Println(section.title)
+			for _, records := range section.records {
+				both := ""
+				if records.matchedBy(githubLabelFailingTest) && records.matchedBy(githubLabelFlake) {
+					both = " (failing test and flake)"
+				}
+				fmt.Printf("%s %s %s%s\n", issueReference(records), records.Title, records.Sig, both)
+				if !meta.Flags.ShortOn {
+					fmt.Printf("- %s\n", records.URL)
+				}
+				for _, note := range records.Notes {
+					fmt.Printf("- %s\n", note)
+				}
+				if len(records.Attention) != 0 {
+					attention = append(attention, records)
+				}
+			}
+		}
+	}
+	if unassigned := UnassignedIssuesBySig(Report{reportData}); len(unassigned) != 0 {
+		fmt.Print("\nUNASSIGNED ISSUES BY SIG:\n")
+		for _, sigReport := range unassigned {
+			ids := []string{}
+			for _, issue := range sigReport.Issues {
+				ids = append(ids, issueReference(issue))
+			}
+			fmt.Printf("%s: %d (%s)\n", sigReport.Sig, len(sigReport.Issues), strings.Join(ids, ", "))
+		}
+	}
+	if len(attention) != 0 {
+		fmt.Print("\nNEEDS ATTENTION:\n")
+		for _, record := range attention {
+			fmt.Printf("%s %s (%s)\n", issueReference(record), record.Title, strings.Join(record.Attention, ", "))
+			if !meta.Flags.ShortOn {
+				fmt.Printf("- %s\n", record.URL)
 			}
 		}
 	}
@@ -96,173 +178,331 @@ func (r GithubReport) GetData() ReportData {
 }
 
 // run all github requests to assemble data
-func transformIntoReportData(meta Meta, issues GithubIssuesAfterID) chan ReportDataField {
+func transformIntoReportData(meta Meta, data GithubIssuesData, filteredOut map[string]int, reqError string) chan ReportDataField {
 	c := make(chan ReportDataField)
-	sigRegex := regexp.MustCompile(`sig/[a-zA-Z]+`)
 	go func() {
 		defer close(c)
-		var wg sync.WaitGroup
-		for _, issue := range issues {
-			wg.Add(1)
-			go func(issue GithubIssueElement) {
-				notes := []string{}
-				// add timestamp to report notes
-				if !meta.Flags.ShortOn {
-					updatedHighlight := ""
-					createdHighlight := ""
-					if !meta.Flags.EmojisOff {
-						if checkTimeBefore(issue.UpdatedAt, time.Now().AddDate(0, -1, 0)) {
-							updatedHighlight += statusFailingEmoji
-						}
-						if !checkTimeBefore(issue.UpdatedAt, time.Now().AddDate(0, 0, -2)) {
-							updatedHighlight += statusNewEmoji
-						}
-						if checkTimeBefore(issue.CreatedAt, time.Now().AddDate(0, -1, 0)) {
-							createdHighlight += statusFailingEmoji
-						}
-						if !checkTimeBefore(issue.CreatedAt, time.Now().AddDate(0, 0, -3)) {
-							createdHighlight += statusNewEmoji
-						}
+		now := time.Now()
+		records := []ReportDataRecord{}
+		for _, issue := range data.Issues {
+			notes := []string{}
+			// add timestamp to report notes
+			if !meta.Flags.ShortOn {
+				updatedHighlight := ""
+				createdHighlight := ""
+				if !meta.Flags.EmojisOff {
+					if checkTimeBefore(issue.UpdatedAt, time.Now().AddDate(0, -1, 0)) {
+						updatedHighlight += statusFailingEmoji
 					}
-					notes = append(notes, fmt.Sprintf("%sCreated %s, %sUpdated %s, Comments: %d", createdHighlight, strings.Split(issue.CreatedAt, "T")[0], updatedHighlight, strings.Split(issue.UpdatedAt, "T")[0], issue.Comments))
-				}
-				// add lables to notes
-				lablesToNote := ""
-				sigsInvolved := []string{}
-				for _, label := range issue.Labels {
-					// filter sigs from notes
-					sig := sigRegex.FindString(label.Name)
-					if sig != "" {
-						sigsInvolved = append(sigsInvolved, sig)
+					if !checkTimeBefore(issue.UpdatedAt, time.Now().AddDate(0, 0, -2)) {
+						updatedHighlight += statusNewEmoji
 					}
-					// filter flag priority & kind/
-					if strings.Contains(label.Name, "priority") {
-						lablesToNote += fmt.Sprintf("%s%s%s ", colorGreen, label.Name, colorReset)
+					if checkTimeBefore(issue.CreatedAt, time.Now().AddDate(0, -1, 0)) {
+						createdHighlight += statusFailingEmoji
 					}
-					if strings.Contains(label.Name, "kind/") {
-						lablesToNote += fmt.Sprintf("%s%s%s ", colorRed, label.Name, colorReset)
+					if !checkTimeBefore(issue.CreatedAt, time.Now().AddDate(0, 0, -3)) {
+						createdHighlight += statusNewEmoji
 					}
 				}
-				// add milestone to lables if it is set
-				if !meta.Flags.ShortOn {
-					if issue.Milestone != nil {
-						lablesToNote += fmt.Sprintf("%smilestone %s%s", colorBlue, issue.Milestone.Title, colorReset)
-					}
+				notes = append(notes, fmt.Sprintf("%sCreated %s, %sUpdated %s, Comments: %d", createdHighlight, strings.Split(issue.CreatedAt, "T")[0], updatedHighlight, strings.Split(issue.UpdatedAt, "T")[0], issue.Comments))
+			}
+			// add lables to notes
+			lablesToNote := ""
+			sigsInvolved := []string{}
+			for _, label := range issue.Labels {
+				// filter sigs from notes
+				sigsInvolved = append(sigsInvolved, findSigs(label.Name)...)
+				// filter flag priority & kind/
+				if strings.Contains(label.Name, "priority") {
+					lablesToNote += fmt.Sprintf("%s%s%s ", colorGreen, label.Name, colorReset)
 				}
-				if lablesToNote != "" {
-					notes = append(notes, lablesToNote)
+				if strings.Contains(label.Name, "kind/") {
+					lablesToNote += fmt.Sprintf("%s%s%s ", colorRed, label.Name, colorReset)
 				}
-				// set information in ReportDataRecord
-				c <- ReportDataField{
-					Emoji: "",
-					Title: "",
-					Records: []ReportDataRecord{
-						{
-							URL:   issue.HTMLURL,
-							ID:    issue.Number,
-							Title: issue.Title,
-							Notes: notes,
-							Sig:   fmt.Sprintf("%v", sigsInvolved),
-						},
-					},
+			}
+			sigsInvolved = uniqueSortedStrings(sigsInvolved)
+			// add milestone to lables if it is set
+			if !meta.Flags.ShortOn {
+				if issue.Milestone != nil {
+					lablesToNote += fmt.Sprintf("%smilestone %s%s", colorBlue, issue.Milestone.Title, colorReset)
 				}
-				wg.Done()
-			}(issue)
+			}
+			if lablesToNote != "" {
+				notes = append(notes, lablesToNote)
+			}
+			// set information in ReportDataRecord
+			record := ReportDataRecord{
+				URL:            issue.HTMLURL,
+				ID:             issue.Number,
+				Title:          issue.Title,
+				Notes:          notes,
+				Sig:            formatSigs(sigsInvolved),
+				Sigs:           sigsInvolved,
+				Labels:         labelNames(issue.Labels),
+				Severity:       getIssueSeverity(issue),
+				CreatedAt:      parseGithubTime(issue.CreatedAt),
+				UpdatedAt:      parseGithubTime(issue.UpdatedAt),
+				Repo:           issueRepo(issue),
+				Milestone:      milestoneTitle(issue.Milestone),
+				Comments:       issue.Comments,
+				Author:         issue.User.Login,
+				PullRequests:   data.PullRequests[issue.Key()],
+				ProjectColumns: data.ProjectColumns[issue.Key()],
+				MatchedBy:      data.MatchedBy[issue.Key()],
+			}
+			var lastComment *GithubComment
+			if comment, ok := data.LastComments[issue.Key()]; ok {
+				lastComment = &comment
+			}
+			analyzePullRequests(&record, issue)
+			analyzeIssueActivity(&record, issue, lastComment, meta.Flags.AttentionDays, now)
+			if !meta.Flags.ShortOn {
+				record.Notes = append(record.Notes, issueActivityNote(record))
+				if len(record.PullRequests) != 0 {
+					record.Notes = append(record.Notes, pullRequestsNote(record))
+				}
+				if len(record.ProjectColumns) != 0 {
+					record.Notes = append(record.Notes, fmt.Sprintf("Project columns: %s", strings.Join(record.ProjectColumns, ", ")))
+				}
+			}
+			records = append(records, record)
+		}
+		records = filterRecordsBySig(meta, records)
+		sortRecords(records, meta.Flags.SortBy)
+		c <- ReportDataField{
+			Emoji:       "",
+			Title:       "",
+			Records:     records,
+			Error:       reqError,
+			FilteredOut: filteredOut,
 		}
-		wg.Wait()
 	}()
 	return c
 }
 
-// GetGithubIssues get github issues
-func GetGithubIssues(cfg GithubIssueRequest) GithubIssuesAfterID {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/issues%s", cfg.Owner, cfg.Repo, "?state=open")
+// IssueSections github issues split by the label queries that found them
+type IssueSections struct {
+	FailingTests []ReportDataRecord
+	Flakes       []ReportDataRecord
+}
+
+// SplitIssues splits issue records into failing tests and flakes, issues matched by both labels are part of the
+// failing tests only (records without MatchedBy, like the ones of older snapshots, are too)
+func SplitIssues(records []ReportDataRecord) IssueSections {
+	sections := IssueSections{FailingTests: []ReportDataRecord{}, Flakes: []ReportDataRecord{}}
+	for _, record := range records {
+		if record.matchedBy(githubLabelFlake) && !record.matchedBy(githubLabelFailingTest) {
+			sections.Flakes = append(sections.Flakes, record)
+		} else {
+			sections.FailingTests = append(sections.FailingTests, record)
+		}
+	}
+	return sections
+}
+
+// matchedBy checks if an issue has been found by the query of label
+func (r ReportDataRecord) matchedBy(label string) bool {
+	return containsString(r.MatchedBy, label)
+}
+
+// issueActivityNote summarizes the age, inactivity, assignees and last comment of an issue
+func issueActivityNote(record ReportDataRecord) string {
+	parts := []string{fmt.Sprintf("Age: %s", record.AgeBucket), fmt.Sprintf("%d days since last update", record.InactiveDays)}
+	if record.Author != "" {
+		parts = append(parts, fmt.Sprintf("opened by %s", record.Author))
+	}
+	if len(record.Assignees) == 0 {
+		parts = append(parts, "no assignee")
+	} else {
+		parts = append(parts, fmt.Sprintf("assigned to %s", strings.Join(record.Assignees, ", ")))
+	}
+	if record.LastCommentBy != "" {
+		lastComment := fmt.Sprintf("last comment by %s", record.LastCommentBy)
+		if record.LastCommentByBot {
+			lastComment += " (bot)"
+		}
+		parts = append(parts, lastComment)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// pullRequestsNote lists the pull requests that reference an issue like
+// 'Pull requests: #123 (open, ci failure), #99 (merged)'
+func pullRequestsNote(record ReportDataRecord) string {
+	pullRequests := []string{}
+	for _, pr := range record.PullRequests {
+		if pr.CIStatus != "" {
+			pullRequests = append(pullRequests, fmt.Sprintf("#%d (%s, ci %s)", pr.Number, pr.State, pr.CIStatus))
+		} else {
+			pullRequests = append(pullRequests, fmt.Sprintf("#%d (%s)", pr.Number, pr.State))
+		}
+	}
+	return fmt.Sprintf("Pull requests: %s", strings.Join(pullRequests, ", "))
+}
+
+// issueRepo returns the repository of an issue like 'kubernetes/kubernetes'
+func issueRepo(issue GithubIssueElement) string {
+	return strings.TrimPrefix(issue.RepositoryURL, githubAPIURL+"/repos/")
+}
+
+// issueReference returns '#123' for issues of kubernetes/kubernetes and 'owner/repo#123' for issues of other
+// repositories
+func issueReference(record ReportDataRecord) string {
+	if record.Repo == "" || record.Repo == defaultGithubRepo {
+		return fmt.Sprintf("#%d", record.ID)
+	}
+	return fmt.Sprintf("%s#%d", record.Repo, record.ID)
+}
+
+func milestoneTitle(milestone *Milestone) string {
+	if milestone == nil {
+		return ""
+	}
+	return milestone.Title
+}
+
+// labelNames returns the names of github labels
+func labelNames(labels []Label) []string {
+	names := []string{}
+	for _, label := range labels {
+		names = append(names, label.Name)
+	}
+	return names
+}
+
+// The priority label of an issue is used to rank it
+func getIssueSeverity(issue GithubIssueElement) Severity {
+	severity := Severity(0)
+	for _, label := range issue.Labels {
+		labelSeverity := Severity(0)
+		switch label.Name {
+		case "priority/critical-urgent":
+			labelSeverity = HighSeverity
+		case "priority/important-soon":
+			labelSeverity = MediumSeverity
+		case "priority/important-longterm":
+			labelSeverity = LightSeverity
+		}
+		if labelSeverity > severity {
+			severity = labelSeverity
+		}
+	}
+	return severity
+}
+
+// GetGithubIssues get github issues of a repository or of the search set in cfg, the issues that have been collected
+// until an error occurred are returned with the error
+func GetGithubIssues(ctx context.Context, fetcher *Fetcher, cfg GithubIssueRequest) (GithubIssuesAfterID, error) {
+	if cfg.Search != "" {
+		return searchGithubIssues(ctx, fetcher, cfg)
+	}
+	url := fmt.Sprintf("%s/repos/%s/%s/issues%s", githubAPIURL, cfg.Owner, cfg.Repo, "?state=open")
 	for param, val := range cfg.Params {
 		url += fmt.Sprintf("&%s=%s", param, val)
 	}
 	collectedIssues := GithubIssuesAfterID{}
-	for issues := range assembleGithubIssues(url, cfg.AuthToken) {
-		for k, issue := range issues {
+	var err error
+	for page := range assembleGithubIssues(ctx, fetcher, url, cfg.AuthToken) {
+		if page.Err != nil {
+			err = page.Err
+			continue
+		}
+		for k, issue := range page.Issues {
 			collectedIssues[k] = issue
 		}
 	}
-	return collectedIssues
+	return collectedIssues, err
+}
+
+// githubIssuesPage issues of a single page or the error that occurred while requesting it
+type githubIssuesPage struct {
+	Issues GithubIssuesAfterID
+	Err    error
 }
 
-func assembleGithubIssues(url string, authToken string) chan GithubIssuesAfterID {
-	c := make(chan GithubIssuesAfterID)
+func assembleGithubIssues(ctx context.Context, fetcher *Fetcher, url string, authToken string) chan githubIssuesPage {
+	c := make(chan githubIssuesPage)
 	go func() {
 		defer close(c)
 		wg := sync.WaitGroup{}
 		wg.Add(1)
-		go requestGithubIssues(c, &wg, url, 1, authToken)
+		go requestGithubIssues(ctx, fetcher, c, &wg, url, 1, authToken)
 		wg.Wait()
 	}()
 	return c
 }
 
 // requestGithubIssues sends a http request to github to list issues
-func requestGithubIssues(c chan GithubIssuesAfterID, wg *sync.WaitGroup, url string, page int, authToken string) {
-	url = fmt.Sprintf("%s&%s=%d", url, string(IssueReqParamPage), page)
-	req, err := http.NewRequest("GET", url, nil)
+func requestGithubIssues(ctx context.Context, fetcher *Fetcher, c chan githubIssuesPage, wg *sync.WaitGroup, url string, page int, authToken string) {
+	defer wg.Done()
+	requestedIssues, err := reqGithubIssuesPage(ctx, fetcher, fmt.Sprintf("%s&%s=%d", url, string(IssueReqParamPage), page), authToken)
 	if err != nil {
-		log.Fatalf("Error on creating http request.\n[ERROR] -%v", err)
+		c <- githubIssuesPage{Err: err}
+		return
 	}
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", authToken))
-	// Send http request
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Fatalf("Error on sending http request.\n[ERROR] -%v", err)
+	// if result is not empty, request data from next website too
+	if len(requestedIssues) != 0 {
+		page++
+		wg.Add(1)
+		go requestGithubIssues(ctx, fetcher, c, wg, url, page, authToken)
 	}
-	defer resp.Body.Close()
-	// Read body and unmarshal bytes
-	body, err := ioutil.ReadAll(resp.Body)
+	c <- githubIssuesPage{Issues: filterGithubIssues(requestedIssues)}
+}
+
+// reqGithubIssuesPage requests a single page of github issues
+func reqGithubIssuesPage(ctx context.Context, fetcher *Fetcher, url string, authToken string) (GithubIssues, error) {
+	resp, err := fetcher.Get(ctx, url, githubHeader(authToken))
 	if err != nil {
-		log.Fatalf("Error on read from response body.\n[ERROR] -%v", err)
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s: %s", url, resp.Status, string(resp.Body))
 	}
-	requestedIssues, err := UnmarshalGithubIssue(body)
+	requestedIssues, err := UnmarshalGithubIssue(resp.Body)
 	if err != nil {
-		fmt.Println(url)
-		fmt.Println(string(body))
-		log.Fatalf("Error on UnmarshalGithubIssue.\n[ERROR] -%v", err)
+		return nil, fmt.Errorf("error on UnmarshalGithubIssue %s: %v", url, err)
 	}
-	// if result is not empty, request data from next website too
-	if len(requestedIssues) != 0 {
-		page++
-		wg.Add(1)
-		go requestGithubIssues(c, wg, url, page, authToken)
+	return requestedIssues, nil
+}
+
+// githubHeader returns the headers that are sent with every github api request
+func githubHeader(authToken string) http.Header {
+	header := http.Header{}
+	header.Set("Accept", "application/vnd.github.v3+json")
+	if authToken != "" {
+		header.Set("Authorization", fmt.Sprintf("Bearer %s", authToken))
 	}
-	c <- filterGithubIssues(requestedIssues)
-	wg.Done()
+	return header
 }
 
+// filterGithubIssues drops pull requests (the issues api lists them too), the label rules are applied by
+// GithubFilterRules once all issues have been requested
 func filterGithubIssues(issues GithubIssues) GithubIssuesAfterID {
 	filteredIssues := GithubIssuesAfterID{}
 	for _, i := range issues {
-		fine := true
-		for _, label := range i.Labels {
-			// issues should not contain any of these lables
-			fine = fine && !strings.Contains(label.Name, "priority/backlog")
-			fine = fine && !strings.Contains(label.Name, "triage/accepted")
-			fine = fine && !strings.Contains(label.Name, "lifecycle/rotten")
-			fine = fine && !strings.Contains(label.Name, "lifecycle/stale")
-		}
-		// issues should not be a pull request
-		fine = fine && !strings.Contains(i.HTMLURL, "pull")
-		if fine {
-			filteredIssues[i.Number] = i
+		if !strings.Contains(i.HTMLURL, "/pull/") {
+			filteredIssues[i.Key()] = i
 		}
 	}
 	return filteredIssues
 }
 
 func checkTimeBefore(s string, u time.Time) bool {
-	layout := "2006-01-02T15:04:05Z"
-	t, _ := time.Parse(layout, s)
+	t, _ := time.Parse(githubTimeLayout, s)
 	return t.Before(u)
 }
 
+// parseGithubTime parses github timestamps, nil is returned if the timestamp is not set
+func parseGithubTime(s string) *time.Time {
+	t, err := time.Parse(githubTimeLayout, s)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+const githubTimeLayout = "2006-01-02T15:04:05Z"
+
 // GITHUB REQUEST
 
 // GithubIssueRequestParameters used to define how to pull issues from github useing GetGithubIssues
@@ -282,19 +522,63 @@ const (
 
 // GithubIssueRequest used to define how to gather github issue information
 type GithubIssueRequest struct {
-	Owner     string
-	Repo      string
+	Owner string
+	Repo  string
+	// Search qualifiers of the github search api (like 'org:kubernetes'), Owner & Repo are not used if it is set
+	Search    string
 	Params    GithubIssueRequestParameters
 	AuthToken string
 }
 
+// source describes where the issues are requested from like 'kubernetes/kubernetes' or 'search org:kubernetes'
+func (r GithubIssueRequest) source() string {
+	if r.Search != "" {
+		return fmt.Sprintf("search %s", r.Search)
+	}
+	return fmt.Sprintf("%s/%s", r.Owner, r.Repo)
+}
+
 // GITHUB ISSUES
 
 // GithubIssues contains multiple GithubIssueElement
 type GithubIssues []GithubIssueElement
 
-// GithubIssuesAfterID issue id points to GithubIssueElement
-type GithubIssuesAfterID map[int64]GithubIssueElement
+// GithubIssuesAfterID issue key ('owner/repo#number', issue numbers collide across repositories) points to
+// GithubIssueElement
+type GithubIssuesAfterID map[string]GithubIssueElement
+
+// GithubIssuesData the issues of the report and what has been requested about them by issue key
+type GithubIssuesData struct {
+	Issues GithubIssuesAfterID
+	// MatchedBy labels of the queries that found an issue (like 'kind/flake')
+	MatchedBy    map[string][]string
+	LastComments map[string]GithubComment
+	PullRequests map[string][]LinkedPullRequest
+	// ProjectColumns columns of the projects an issue is in
+	ProjectColumns map[string][]string
+}
+
+// NewGithubIssuesData creates an empty GithubIssuesData
+func NewGithubIssuesData() GithubIssuesData {
+	return GithubIssuesData{
+		Issues:         GithubIssuesAfterID{},
+		MatchedBy:      map[string][]string{},
+		LastComments:   map[string]GithubComment{},
+		PullRequests:   map[string][]LinkedPullRequest{},
+		ProjectColumns: map[string][]string{},
+	}
+}
+
+// AddIssue adds an issue found by the query of label, an issue found by several queries is kept once with all labels
+// (not safe for concurrent use)
+func (d GithubIssuesData) AddIssue(issue GithubIssueElement, label string) {
+	key := issue.Key()
+	d.Issues[key] = issue
+	if !containsString(d.MatchedBy[key], label) {
+		d.MatchedBy[key] = append(d.MatchedBy[key], label)
+		sort.Strings(d.MatchedBy[key])
+	}
+}
 
 // UnmarshalGithubIssue transforms []byte into GithubIssues
 func UnmarshalGithubIssue(data []byte) (GithubIssues, error) {
@@ -310,16 +594,26 @@ func (r *GithubIssues) Marshal() ([]byte, error) {
 
 // GithubIssueElement github issue information
 type GithubIssueElement struct {
-	HTMLURL   string     `json:"html_url"`
-	Number    int64      `json:"number"`
-	Title     string     `json:"title"`
-	Labels    []Label    `json:"labels"`
-	State     string     `json:"state"`
-	Milestone *Milestone `json:"milestone"`
-	Comments  int64      `json:"comments"`
-	CreatedAt string     `json:"created_at"`
-	UpdatedAt string     `json:"updated_at"`
-	ClosedAt  string     `json:"closed_at"`
+	HTMLURL       string       `json:"html_url"`
+	RepositoryURL string       `json:"repository_url"`
+	CommentsURL   string       `json:"comments_url"`
+	TimelineURL   string       `json:"timeline_url"`
+	User          GithubUser   `json:"user"`
+	Number        int64        `json:"number"`
+	Title         string       `json:"title"`
+	Labels        []Label      `json:"labels"`
+	State         string       `json:"state"`
+	Assignees     []GithubUser `json:"assignees"`
+	Milestone     *Milestone   `json:"milestone"`
+	Comments      int64        `json:"comments"`
+	CreatedAt     string       `json:"created_at"`
+	UpdatedAt     string       `json:"updated_at"`
+	ClosedAt      string       `json:"closed_at"`
+}
+
+// Key identifies an issue across repositories like 'kubernetes/kubernetes#123'
+func (i GithubIssueElement) Key() string {
+	return fmt.Sprintf("%s#%d", issueRepo(i), i.Number)
 }
 
 // Label github label
@@ -0,0 +1,95 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	// githubAPIURL base url of the github api
+	githubAPIURL = "https://api.github.com"
+	// defaultGithubRepo repository the issues are requested from if -repos is not set
+	defaultGithubRepo = "kubernetes/kubernetes"
+	// githubIssuesSince issues that have not been updated since are not requested
+	githubIssuesSince = "2021-09-01"
+	// searchPerPage number of search results requested per page (maximum of the github api)
+	searchPerPage = 100
+	// searchMaxResults the github search api returns at most 1000 results per query
+	searchMaxResults = 1000
+)
+
+// Labels of the issues that are part of the report
+const (
+	githubLabelFailingTest = "kind/failing-test"
+	githubLabelFlake       = "kind/flake"
+)
+
+// githubIssueLabels issues with one of these labels are part of the report
+var githubIssueLabels = []string{githubLabelFailingTest, githubLabelFlake}
+
+// githubSearchResult page of the github search api
+type githubSearchResult struct {
+	TotalCount        int                  `json:"total_count"`
+	IncompleteResults bool                 `json:"incomplete_results"`
+	Items             []GithubIssueElement `json:"items"`
+}
+
+// searchGithubIssues requests the open issues that match the search qualifiers and the label of cfg through the
+// github search api (like 'org:kubernetes label:kind/flake is:issue is:open')
+func searchGithubIssues(ctx context.Context, fetcher *Fetcher, cfg GithubIssueRequest) (GithubIssuesAfterID, error) {
+	query := githubSearchQuery(cfg)
+	collectedIssues := GithubIssuesAfterID{}
+	for page := 1; page*searchPerPage <= searchMaxResults; page++ {
+		reqURL := fmt.Sprintf("%s/search/issues?q=%s&sort=updated&per_page=%d&page=%d", githubAPIURL, url.QueryEscape(query), searchPerPage, page)
+		var result githubSearchResult
+		if err := reqGithubJSON(ctx, fetcher, reqURL, cfg.AuthToken, &result); err != nil {
+			return collectedIssues, err
+		}
+		for _, issue := range result.Items {
+			// search results do not link the timeline
+			if issue.TimelineURL == "" && issue.RepositoryURL != "" {
+				issue.TimelineURL = fmt.Sprintf("%s/issues/%d/timeline", issue.RepositoryURL, issue.Number)
+			}
+			collectedIssues[issue.Key()] = issue
+		}
+		if result.IncompleteResults {
+			return collectedIssues, fmt.Errorf("the search '%s' timed out, results are incomplete", query)
+		}
+		if len(result.Items) < searchPerPage || page*searchPerPage >= result.TotalCount {
+			return collectedIssues, nil
+		}
+	}
+	return collectedIssues, fmt.Errorf("the search '%s' has more than %d results, narrow it down", query, searchMaxResults)
+}
+
+// githubSearchQuery returns the search query of cfg like 'org:kubernetes label:"kind/flake" is:issue is:open
+// updated:>=2021-09-01'
+func githubSearchQuery(cfg GithubIssueRequest) string {
+	qualifiers := []string{cfg.Search}
+	if label := cfg.Params[IssueReqParamLabels]; label != "" {
+		qualifiers = append(qualifiers, fmt.Sprintf("label:%q", label))
+	}
+	qualifiers = append(qualifiers, "is:issue", "is:open")
+	if since := cfg.Params[IssueReqParamSince]; since != "" {
+		qualifiers = append(qualifiers, fmt.Sprintf("updated:>=%s", since))
+	}
+	return strings.Join(qualifiers, " ")
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultAttentionDays issues without human activity for this number of days need attention (-attention-days)
+const defaultAttentionDays = 14
+
+// Age buckets of github issues
+const (
+	ageBucketWeek        = "< 1 week"
+	ageBucketMonth       = "1-4 weeks"
+	ageBucketQuarter     = "1-3 months"
+	ageBucketOlder       = "> 3 months"
+	ageBucketWeekDays    = 7
+	ageBucketMonthDays   = 28
+	ageBucketQuarterDays = 91
+)
+
+// githubBots accounts that comment on issues automatically but are not marked as bots by github
+var githubBots = []string{"k8s-ci-robot", "k8s-triage-robot", "fejta-bot", "k8s-github-robot"}
+
+// GithubUser github account
+type GithubUser struct {
+	Login string `json:"login"`
+	// Type 'User' or 'Bot'
+	Type string `json:"type"`
+}
+
+// GithubComment github issue comment
+type GithubComment struct {
+	User      GithubUser `json:"user"`
+	CreatedAt string     `json:"created_at"`
+}
+
+// isGithubBot checks if an account comments automatically
+func isGithubBot(user GithubUser) bool {
+	return user.Type == "Bot" || strings.HasSuffix(user.Login, "[bot]") || containsString(githubBots, user.Login)
+}
+
+// requestLastComments requests the last comment of every issue that has comments, the comments that could be
+// requested are returned with the first error
+func requestLastComments(ctx context.Context, fetcher *Fetcher, issues GithubIssuesAfterID, authToken string) (map[string]GithubComment, error) {
+	comments := map[string]GithubComment{}
+	var mu sync.Mutex
+	failed, err := forEachIssue(issues, func(issue GithubIssueElement) bool {
+		return issue.Comments != 0 && issue.CommentsURL != ""
+	}, func(issue GithubIssueElement) error {
+		comment, err := reqLastComment(ctx, fetcher, issue, authToken)
+		if err != nil || comment == nil {
+			return err
+		}
+		mu.Lock()
+		comments[issue.Key()] = *comment
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return comments, fmt.Errorf("could not request the last comment of %d issues: %v", failed, err)
+	}
+	return comments, nil
+}
+
+// reqLastComment requests the last comment of an issue, with one comment per page the last page is the last comment
+func reqLastComment(ctx context.Context, fetcher *Fetcher, issue GithubIssueElement, authToken string) (*GithubComment, error) {
+	url := fmt.Sprintf("%s?per_page=1&page=%d", issue.CommentsURL, issue.Comments)
+	resp, err := fetcher.Get(ctx, url, githubHeader(authToken))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	var comments []GithubComment
+	if err := json.Unmarshal(resp.Body, &comments); err != nil {
+		return nil, fmt.Errorf("could not unmarshal %s: %v", url, err)
+	}
+	if len(comments) == 0 {
+		return nil, nil
+	}
+	return &comments[len(comments)-1], nil
+}
+
+// issueAgeBucket groups issues by age like '1-4 weeks'
+func issueAgeBucket(createdAt *time.Time, now time.Time) string {
+	if createdAt == nil {
+		return ""
+	}
+	days := int(now.Sub(*createdAt).Hours() / 24)
+	switch {
+	case days < ageBucketWeekDays:
+		return ageBucketWeek
+	case days < ageBucketMonthDays:
+		return ageBucketMonth
+	case days < ageBucketQuarterDays:
+		return ageBucketQuarter
+	}
+	return ageBucketOlder
+}
+
+// Reasons why an issue needs attention in addition to the activity and fixMergedAttention
+const (
+	noSigAttention      = "no sig label"
+	noAssigneeAttention = "no assignee"
+)
+
+// analyzeIssueActivity sets the age, inactivity, assignees and last comment of an issue record and the reasons why
+// the issue needs attention: a bot comment nobody reacted to for attentionDays, no update for attentionDays or no sig
+// label. Issues that need attention are flagged if nobody is assigned as well. The text report and the weekly summary
+// both list the issues with these reasons, so analyzePullRequests has to run before.
+func analyzeIssueActivity(record *ReportDataRecord, issue GithubIssueElement, lastComment *GithubComment, attentionDays int, now time.Time) {
+	record.AgeBucket = issueAgeBucket(record.CreatedAt, now)
+	if record.UpdatedAt != nil {
+		record.InactiveDays = int(now.Sub(*record.UpdatedAt).Hours() / 24)
+	}
+	for _, assignee := range issue.Assignees {
+		record.Assignees = append(record.Assignees, assignee.Login)
+	}
+
+	var lastCommentAt *time.Time
+	if lastComment != nil {
+		record.LastCommentBy = lastComment.User.Login
+		record.LastCommentByBot = isGithubBot(lastComment.User)
+		lastCommentAt = parseGithubTime(lastComment.CreatedAt)
+	}
+	if record.LastCommentByBot {
+		// bots bump the update time, so only the bot comment tells that nobody has looked at the issue since
+		if lastCommentAt != nil && int(now.Sub(*lastCommentAt).Hours()/24) >= attentionDays {
+			record.Attention = append(record.Attention, fmt.Sprintf("last comment by %s", record.LastCommentBy))
+		}
+	} else if record.UpdatedAt != nil && record.InactiveDays >= attentionDays {
+		record.Attention = append(record.Attention, fmt.Sprintf("no update for %d days", record.InactiveDays))
+	}
+	if len(record.Sigs) == 0 {
+		record.Attention = append(record.Attention, noSigAttention)
+	}
+	if len(record.Attention) != 0 && len(record.Assignees) == 0 {
+		record.Attention = append(record.Attention, noAssigneeAttention)
+	}
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// activityNow fixed time the issue activity is analyzed at
+var activityNow = time.Date(2021, time.October, 1, 12, 0, 0, 0, time.UTC)
+
+// daysAgo returns the time days before activityNow
+func daysAgo(days float64) *time.Time {
+	t := activityNow.Add(-time.Duration(days * float64(24*time.Hour)))
+	return &t
+}
+
+func TestIssueAgeBucket(t *testing.T) {
+	tests := []struct {
+		createdAt *time.Time
+		want      string
+	}{
+		{createdAt: nil, want: ""},
+		{createdAt: daysAgo(0), want: ageBucketWeek},
+		{createdAt: daysAgo(6.9), want: ageBucketWeek},
+		{createdAt: daysAgo(7), want: ageBucketMonth},
+		{createdAt: daysAgo(27.9), want: ageBucketMonth},
+		{createdAt: daysAgo(28), want: ageBucketQuarter},
+		{createdAt: daysAgo(90.9), want: ageBucketQuarter},
+		{createdAt: daysAgo(91), want: ageBucketOlder},
+		{createdAt: daysAgo(400), want: ageBucketOlder},
+	}
+	for _, tt := range tests {
+		if got := issueAgeBucket(tt.createdAt, activityNow); got != tt.want {
+			t.Errorf("issueAgeBucket(%v) = %q, want %q", tt.createdAt, got, tt.want)
+		}
+	}
+}
+
+func TestAnalyzeIssueActivity(t *testing.T) {
+	human := GithubUser{Login: "alice", Type: "User"}
+	bot := GithubUser{Login: "k8s-triage-robot", Type: "User"}
+	assigned := []GithubUser{human}
+	comment := func(user GithubUser, days float64) *GithubComment {
+		return &GithubComment{User: user, CreatedAt: daysAgo(days).Format(githubTimeLayout)}
+	}
+
+	tests := []struct {
+		name        string
+		updatedAt   *time.Time
+		sigs        []string
+		assignees   []GithubUser
+		lastComment *GithubComment
+		attention   []string
+		want        []string
+	}{
+		{name: "recently updated", updatedAt: daysAgo(2), sigs: []string{"node"}, assignees: assigned},
+		{name: "no update", updatedAt: daysAgo(14), sigs: []string{"node"}, assignees: assigned, want: []string{"no update for 14 days"}},
+		{name: "just not inactive", updatedAt: daysAgo(13.9), sigs: []string{"node"}, assignees: assigned},
+		{name: "human comment", updatedAt: daysAgo(20), sigs: []string{"node"}, assignees: assigned, lastComment: comment(human, 20), want: []string{"no update for 20 days"}},
+		// bots bump the update time, the bot comment decides
+		{name: "recent bot comment", updatedAt: daysAgo(1), sigs: []string{"node"}, assignees: assigned, lastComment: comment(bot, 1)},
+		{name: "bot comment at the threshold", updatedAt: daysAgo(14), sigs: []string{"node"}, assignees: assigned, lastComment: comment(bot, 14), want: []string{"last comment by k8s-triage-robot"}},
+		{name: "bot comment below the threshold", updatedAt: daysAgo(13.9), sigs: []string{"node"}, assignees: assigned, lastComment: comment(bot, 13.9)},
+		{name: "github app comment", updatedAt: daysAgo(30), sigs: []string{"node"}, assignees: assigned, lastComment: comment(GithubUser{Login: "renovate[bot]", Type: "Bot"}, 30), want: []string{"last comment by renovate[bot]"}},
+		{name: "no sig label", updatedAt: daysAgo(2), assignees: assigned, want: []string{noSigAttention}},
+		// issues that need no attention are listed as unassigned by sig only
+		{name: "no assignee", updatedAt: daysAgo(2), sigs: []string{"node"}},
+		{name: "no update and no assignee", updatedAt: daysAgo(30), sigs: []string{"node"}, want: []string{"no update for 30 days", noAssigneeAttention}},
+		{name: "no sig label and no assignee", updatedAt: daysAgo(2), want: []string{noSigAttention, noAssigneeAttention}},
+		{name: "fix merged and no assignee", updatedAt: daysAgo(2), sigs: []string{"node"}, attention: []string{fixMergedAttention}, want: []string{fixMergedAttention, noAssigneeAttention}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := ReportDataRecord{CreatedAt: daysAgo(40), UpdatedAt: tt.updatedAt, Sigs: tt.sigs, Attention: tt.attention}
+			issue := GithubIssueElement{Assignees: tt.assignees}
+			analyzeIssueActivity(&record, issue, tt.lastComment, defaultAttentionDays, activityNow)
+			if !reflect.DeepEqual(record.Attention, tt.want) {
+				t.Errorf("Attention = %q, want %q", record.Attention, tt.want)
+			}
+			if record.AgeBucket != ageBucketQuarter {
+				t.Errorf("AgeBucket = %q, want %q", record.AgeBucket, ageBucketQuarter)
+			}
+		})
+	}
+}
+
+func TestAnalyzeIssueActivityRecord(t *testing.T) {
+	record := ReportDataRecord{CreatedAt: daysAgo(3), UpdatedAt: daysAgo(2.5), Sigs: []string{"node"}}
+	issue := GithubIssueElement{Assignees: []GithubUser{{Login: "alice"}, {Login: "bob"}}}
+	analyzeIssueActivity(&record, issue, &GithubComment{User: GithubUser{Login: "fejta-bot"}, CreatedAt: daysAgo(2.5).Format(githubTimeLayout)}, defaultAttentionDays, activityNow)
+	want := ReportDataRecord{
+		CreatedAt:        record.CreatedAt,
+		UpdatedAt:        record.UpdatedAt,
+		Sigs:             []string{"node"},
+		AgeBucket:        ageBucketWeek,
+		InactiveDays:     2,
+		Assignees:        []string{"alice", "bob"},
+		LastCommentBy:    "fejta-bot",
+		LastCommentByBot: true,
+	}
+	if !reflect.DeepEqual(record, want) {
+		t.Errorf("analyzeIssueActivity() = %+v, want %+v", record, want)
+	}
+}
@@ -0,0 +1,283 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// timelinePerPage number of timeline events requested per page (maximum of the github api)
+const timelinePerPage = 100
+
+// States of pull requests that reference an issue
+const (
+	pullRequestOpen   = "open"
+	pullRequestMerged = "merged"
+	pullRequestClosed = "closed"
+)
+
+// fixMergedAttention reason an open issue needs attention if a pull request that references it has been merged
+const fixMergedAttention = "fix merged, verify and close"
+
+// LinkedPullRequest a pull request that references a github issue
+type LinkedPullRequest struct {
+	Number int64  `json:"number"`
+	URL    string `json:"url"`
+	Title  string `json:"title"`
+	// State 'open', 'merged' or 'closed'
+	State string `json:"state"`
+	// CIStatus combined status of the head commit of open pull requests ('success', 'pending' or 'failure')
+	CIStatus string `json:"ci_status,omitempty"`
+	// apiURL url of the pull request in the github api
+	apiURL string
+}
+
+// githubTimelineEvent event of the timeline of a github issue, only cross references and project card events are
+// decoded
+type githubTimelineEvent struct {
+	Event  string `json:"event"`
+	Source *struct {
+		Issue *githubTimelineIssue `json:"issue"`
+	} `json:"source"`
+	ProjectCard *struct {
+		ProjectID  int64  `json:"project_id"`
+		ColumnName string `json:"column_name"`
+	} `json:"project_card"`
+}
+
+// githubTimelineIssue issue or pull request that referenced an issue
+type githubTimelineIssue struct {
+	Number      int64  `json:"number"`
+	HTMLURL     string `json:"html_url"`
+	Title       string `json:"title"`
+	State       string `json:"state"`
+	PullRequest *struct {
+		URL      string  `json:"url"`
+		MergedAt *string `json:"merged_at"`
+	} `json:"pull_request"`
+}
+
+// githubPullRequest pull request of the github api, only the fields to request the ci status are decoded
+type githubPullRequest struct {
+	Head struct {
+		SHA string `json:"sha"`
+	} `json:"head"`
+	Base struct {
+		Repo struct {
+			URL string `json:"url"`
+		} `json:"repo"`
+	} `json:"base"`
+}
+
+// githubCombinedStatus combined status of the ci contexts of a commit
+type githubCombinedStatus struct {
+	State string `json:"state"`
+}
+
+// combinedStatusState normalizes the state of the combined status of a commit, the rest api reports errors as
+// 'failure' and commits without any status as 'pending'
+func combinedStatusState(state string) string {
+	switch state = strings.ToLower(state); state {
+	case "error":
+		return "failure"
+	case "", "expected":
+		return "pending"
+	}
+	return state
+}
+
+// forEachIssue runs request concurrently for every issue that matches (the fetcher limits the requests in flight),
+// the number of failed requests is returned with the first error
+func forEachIssue(issues GithubIssuesAfterID, matches func(GithubIssueElement) bool, request func(GithubIssueElement) error) (int, error) {
+	var mu sync.Mutex
+	var firstErr error
+	failed := 0
+	wg := sync.WaitGroup{}
+	for _, issue := range issues {
+		if !matches(issue) {
+			continue
+		}
+		wg.Add(1)
+		go func(issue GithubIssueElement) {
+			defer wg.Done()
+			if err := request(issue); err != nil {
+				mu.Lock()
+				failed++
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}(issue)
+	}
+	wg.Wait()
+	return failed, firstErr
+}
+
+// requestIssueTimelines requests the pull requests that reference the issues and the project columns the issues are
+// in from their timelines, the timelines that could be requested are returned with the first error
+func requestIssueTimelines(ctx context.Context, fetcher *Fetcher, issues GithubIssuesAfterID, authToken string) (map[string][]LinkedPullRequest, map[string][]string, error) {
+	pullRequests := map[string][]LinkedPullRequest{}
+	projectColumns := map[string][]string{}
+	var mu sync.Mutex
+	failed, err := forEachIssue(issues, func(issue GithubIssueElement) bool {
+		return issue.TimelineURL != ""
+	}, func(issue GithubIssueElement) error {
+		linked, columns, err := reqIssueTimeline(ctx, fetcher, issue, authToken)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		pullRequests[issue.Key()] = linked
+		if len(columns) != 0 {
+			projectColumns[issue.Key()] = columns
+		}
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return pullRequests, projectColumns, fmt.Errorf("could not request the timeline of %d issues: %v", failed, err)
+	}
+	return pullRequests, projectColumns, nil
+}
+
+// reqIssueTimeline pages through the timeline of an issue and returns the cross referenced pull requests and the
+// sorted columns of the projects the issue is in. The ci status is requested for the open pull requests (and left
+// empty if it cannot be requested).
+func reqIssueTimeline(ctx context.Context, fetcher *Fetcher, issue GithubIssueElement, authToken string) ([]LinkedPullRequest, []string, error) {
+	linked := map[string]LinkedPullRequest{}
+	// the last event of a project card tells the column of the project the issue is in
+	columnByProject := map[int64]string{}
+	for page := 1; ; page++ {
+		url := fmt.Sprintf("%s?per_page=%d&page=%d", issue.TimelineURL, timelinePerPage, page)
+		var events []githubTimelineEvent
+		if err := reqGithubJSON(ctx, fetcher, url, authToken, &events); err != nil {
+			return nil, nil, err
+		}
+		for _, event := range events {
+			if event.ProjectCard != nil {
+				switch event.Event {
+				case "added_to_project", "moved_columns_in_project":
+					columnByProject[event.ProjectCard.ProjectID] = event.ProjectCard.ColumnName
+				case "removed_from_project":
+					delete(columnByProject, event.ProjectCard.ProjectID)
+				}
+				continue
+			}
+			if event.Event != "cross-referenced" || event.Source == nil || event.Source.Issue == nil || event.Source.Issue.PullRequest == nil {
+				continue
+			}
+			source := event.Source.Issue
+			pr := LinkedPullRequest{Number: source.Number, URL: source.HTMLURL, Title: source.Title, State: source.State, apiURL: source.PullRequest.URL}
+			if source.PullRequest.MergedAt != nil {
+				pr.State = pullRequestMerged
+			}
+			linked[source.HTMLURL] = pr
+		}
+		if len(events) < timelinePerPage {
+			break
+		}
+	}
+	pullRequests := []LinkedPullRequest{}
+	for _, pr := range linked {
+		if pr.State == pullRequestOpen && pr.apiURL != "" {
+			// the ci status is left empty if it cannot be requested (like for a deleted fork), the pull request
+			// itself is still known
+			if ciStatus, err := reqCIStatus(ctx, fetcher, pr.apiURL, authToken); err == nil {
+				pr.CIStatus = ciStatus
+			}
+		}
+		pullRequests = append(pullRequests, pr)
+	}
+	sort.Slice(pullRequests, func(i, j int) bool {
+		return pullRequests[i].URL < pullRequests[j].URL
+	})
+	return pullRequests, projectColumnNames(columnByProject), nil
+}
+
+// projectColumnNames returns the sorted column names of the projects an issue is in
+func projectColumnNames(columnByProject map[int64]string) []string {
+	columns := []string{}
+	for _, column := range columnByProject {
+		if column != "" {
+			columns = append(columns, column)
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// reqCIStatus requests the combined status of the head commit of a pull request
+func reqCIStatus(ctx context.Context, fetcher *Fetcher, pullRequestURL string, authToken string) (string, error) {
+	var pullRequest githubPullRequest
+	if err := reqGithubJSON(ctx, fetcher, pullRequestURL, authToken, &pullRequest); err != nil {
+		return "", err
+	}
+	if pullRequest.Head.SHA == "" || pullRequest.Base.Repo.URL == "" {
+		return "", nil
+	}
+	var status githubCombinedStatus
+	url := fmt.Sprintf("%s/commits/%s/status", pullRequest.Base.Repo.URL, pullRequest.Head.SHA)
+	if err := reqGithubJSON(ctx, fetcher, url, authToken, &status); err != nil {
+		return "", err
+	}
+	return combinedStatusState(status.State), nil
+}
+
+// reqGithubJSON requests a github api url and unmarshals the response into v
+func reqGithubJSON(ctx context.Context, fetcher *Fetcher, url string, authToken string, v interface{}) error {
+	resp, err := fetcher.Get(ctx, url, githubHeader(authToken))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	if err := json.Unmarshal(resp.Body, v); err != nil {
+		return fmt.Errorf("could not unmarshal %s: %v", url, err)
+	}
+	return nil
+}
+
+// analyzePullRequests flags open issues whose fix has been merged
+func analyzePullRequests(record *ReportDataRecord, issue GithubIssueElement) {
+	if issue.State != "open" {
+		return
+	}
+	for _, pr := range record.PullRequests {
+		if pr.State == pullRequestMerged {
+			record.Attention = append(record.Attention, fixMergedAttention)
+			return
+		}
+	}
+}
+
+// HasOpenPullRequest checks if an open pull request references the issue
+func (r ReportDataRecord) HasOpenPullRequest() bool {
+	for _, pr := range r.PullRequests {
+		if pr.State == pullRequestOpen {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newGithubStandIn serves the responses by request path, '{{server}}' in a response is replaced by the url of the
+// stand-in. Handlers answer the requests of their path instead (like the issues or the graphql api), unknown paths
+// are answered with 404.
+func newGithubStandIn(t *testing.T, responses map[string]string, handlers map[string]http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if handler, ok := handlers[r.URL.Path]; ok {
+			handler(w, r)
+			return
+		}
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(strings.Replace(body, "{{server}}", server.URL, -1)))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// githubStandInTransport sends the requests to the github api (and any other host) to the stand-in instead
+type githubStandInTransport struct {
+	target *url.URL
+}
+
+func (t githubStandInTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme = t.target.Scheme
+	r.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// newGithubStandInFetcher returns a fetcher that sends the requests to the stand-in, for code that requests
+// githubAPIURL directly
+func newGithubStandInFetcher(t *testing.T, server *httptest.Server) *Fetcher {
+	t.Helper()
+	target, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("could not parse %s: %v", server.URL, err)
+	}
+	fetcher := NewFetcher(FetcherOptions{Concurrency: 4})
+	fetcher.client = &http.Client{Transport: githubStandInTransport{target: target}}
+	return fetcher
+}
+
+// timelineResponses timeline of issue 1 of the stand-in: an open pull request with failing ci, a merged pull
+// request, an open pull request of a deleted fork, a referencing issue, a label event and the cards of three
+// projects (one moved, one removed)
+var timelineResponses = map[string]string{
+	"/repos/kubernetes/kubernetes/issues/1/timeline": `[
+		{"event": "labeled"},
+		{"event": "added_to_project", "project_card": {"project_id": 10, "column_name": "Triage"}},
+		{"event": "added_to_project", "project_card": {"project_id": 11, "column_name": "Backlog"}},
+		{"event": "added_to_project", "project_card": {"project_id": 12, "column_name": "Observing"}},
+		{"event": "moved_columns_in_project", "project_card": {"project_id": 10, "column_name": "In Progress", "previous_column_name": "Triage"}},
+		{"event": "removed_from_project", "project_card": {"project_id": 11, "column_name": "Backlog"}},
+		{"event": "cross-referenced", "source": {"issue": {"number": 5, "title": "Fix the flake", "state": "open", "html_url": "https://github.com/kubernetes/kubernetes/pull/5", "pull_request": {"url": "{{server}}/repos/kubernetes/kubernetes/pulls/5", "merged_at": null}}}},
+		{"event": "cross-referenced", "source": {"issue": {"number": 4, "title": "Retry the request", "state": "closed", "html_url": "https://github.com/kubernetes/kubernetes/pull/4", "pull_request": {"url": "{{server}}/repos/kubernetes/kubernetes/pulls/4", "merged_at": "2021-10-01T12:00:00Z"}}}},
+		{"event": "cross-referenced", "source": {"issue": {"number": 5, "title": "Fix the flake", "state": "open", "html_url": "https://github.com/kubernetes/kubernetes/pull/5", "pull_request": {"url": "{{server}}/repos/kubernetes/kubernetes/pulls/5", "merged_at": null}}}},
+		{"event": "cross-referenced", "source": {"issue": {"number": 6, "title": "Deleted fork", "state": "open", "html_url": "https://github.com/kubernetes/kubernetes/pull/6", "pull_request": {"url": "{{server}}/repos/kubernetes/kubernetes/pulls/6", "merged_at": null}}}},
+		{"event": "cross-referenced", "source": {"issue": {"number": 3, "title": "Related issue", "state": "open", "html_url": "https://github.com/kubernetes/kubernetes/issues/3"}}}
+	]`,
+	"/repos/kubernetes/kubernetes/pulls/5":               `{"head": {"sha": "abc123"}, "base": {"repo": {"url": "{{server}}/repos/kubernetes/kubernetes"}}}`,
+	"/repos/kubernetes/kubernetes/commits/abc123/status": `{"state": "failure", "statuses": [{"state": "failure", "context": "pull-kubernetes-e2e-gce"}]}`,
+	"/repos/kubernetes/kubernetes/pulls/6":               `{"head": {"sha": "def456"}, "base": {"repo": {"url": "{{server}}/repos/kubernetes/kubernetes"}}}`,
+	"/repos/kubernetes/kubernetes/issues/2/timeline":     `[]`,
+}
+
+func TestRequestIssueTimelines(t *testing.T) {
+	server := newGithubStandIn(t, timelineResponses, nil)
+	issues := GithubIssuesAfterID{}
+	for _, number := range []int64{1, 2} {
+		issue := GithubIssueElement{
+			Number:        number,
+			State:         "open",
+			RepositoryURL: githubAPIURL + "/repos/kubernetes/kubernetes",
+			TimelineURL:   fmt.Sprintf("%s/repos/kubernetes/kubernetes/issues/%d/timeline", server.URL, number),
+		}
+		issues[issue.Key()] = issue
+	}
+
+	pullRequests, projectColumns, err := requestIssueTimelines(context.Background(), NewFetcher(FetcherOptions{}), issues, "token")
+	if err != nil {
+		t.Fatalf("requestIssueTimelines() error = %v", err)
+	}
+	want := []LinkedPullRequest{
+		{Number: 4, URL: "https://github.com/kubernetes/kubernetes/pull/4", Title: "Retry the request", State: pullRequestMerged},
+		{Number: 5, URL: "https://github.com/kubernetes/kubernetes/pull/5", Title: "Fix the flake", State: pullRequestOpen, CIStatus: "failure"},
+		// the ci status of the deleted fork cannot be requested, the pull request is kept
+		{Number: 6, URL: "https://github.com/kubernetes/kubernetes/pull/6", Title: "Deleted fork", State: pullRequestOpen},
+	}
+	got := pullRequests["kubernetes/kubernetes#1"]
+	for i := range got {
+		got[i].apiURL = ""
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pull requests of #1 = %+v, want %+v", got, want)
+	}
+	if got := pullRequests["kubernetes/kubernetes#2"]; len(got) != 0 {
+		t.Errorf("pull requests of #2 = %+v, want none", got)
+	}
+	wantColumns := map[string][]string{"kubernetes/kubernetes#1": {"In Progress", "Observing"}}
+	if !reflect.DeepEqual(projectColumns, wantColumns) {
+		t.Errorf("project columns = %q, want %q", projectColumns, wantColumns)
+	}
+}
+
+func TestRequestIssueTimelinesError(t *testing.T) {
+	server := newGithubStandIn(t, map[string]string{}, nil)
+	issue := GithubIssueElement{Number: 1, RepositoryURL: githubAPIURL + "/repos/kubernetes/kubernetes", TimelineURL: server.URL + "/repos/kubernetes/kubernetes/issues/1/timeline"}
+	_, _, err := requestIssueTimelines(context.Background(), NewFetcher(FetcherOptions{}), GithubIssuesAfterID{issue.Key(): issue}, "token")
+	if err == nil || !strings.Contains(err.Error(), "could not request the timeline of 1 issues") {
+		t.Errorf("requestIssueTimelines() error = %v, want the failed timeline", err)
+	}
+}
+
+func TestAnalyzePullRequests(t *testing.T) {
+	tests := []struct {
+		name         string
+		issueState   string
+		pullRequests []LinkedPullRequest
+		want         []string
+		wantOpen     bool
+	}{
+		{
+			name:         "open issue with merged fix",
+			issueState:   "open",
+			pullRequests: []LinkedPullRequest{{Number: 4, State: pullRequestMerged}, {Number: 5, State: pullRequestOpen, CIStatus: "failure"}},
+			want:         []string{fixMergedAttention},
+			wantOpen:     true,
+		},
+		{
+			name:         "closed issue with merged fix",
+			issueState:   "closed",
+			pullRequests: []LinkedPullRequest{{Number: 4, State: pullRequestMerged}},
+		},
+		{
+			name:         "open issue with closed pull request",
+			issueState:   "open",
+			pullRequests: []LinkedPullRequest{{Number: 4, State: pullRequestClosed}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := ReportDataRecord{PullRequests: tt.pullRequests}
+			analyzePullRequests(&record, GithubIssueElement{State: tt.issueState})
+			if !reflect.DeepEqual(record.Attention, tt.want) {
+				t.Errorf("Attention = %q, want %q", record.Attention, tt.want)
+			}
+			if record.HasOpenPullRequest() != tt.wantOpen {
+				t.Errorf("HasOpenPullRequest() = %v, want %v", record.HasOpenPullRequest(), tt.wantOpen)
+			}
+		})
+	}
+}
+
+func TestPullRequestsNote(t *testing.T) {
+	record := ReportDataRecord{PullRequests: []LinkedPullRequest{{Number: 4, State: pullRequestMerged}, {Number: 5, State: pullRequestOpen, CIStatus: "failure"}}}
+	if got, want := pullRequestsNote(record), "Pull requests: #4 (merged), #5 (open, ci failure)"; got != want {
+		t.Errorf("pullRequestsNote() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// JUnitTestSuites root element of a junit xml report
+type JUnitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []JUnitTestSuite `xml:"testsuite"`
+}
+
+// JUnitTestSuite a testgrid dashboard
+type JUnitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []JUnitTestCase `xml:"testcase"`
+}
+
+// JUnitTestCase a testgrid job
+type JUnitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *JUnitMessage `xml:"failure,omitempty"`
+	Error     *JUnitMessage `xml:"error,omitempty"`
+	Skipped   *JUnitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+// JUnitMessage content of a failure, error or skipped element
+type JUnitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",cdata"`
+}
+
+// NewJUnitReport returns one testcase per job of every testgrid dashboard. Failing jobs are failures, stale jobs
+// are skipped, flaky jobs pass (with a note in system-out) and dashboards that could not be requested are errors.
+// Failing and flaky jobs without details (left out with -short or filtered by -sig) are taken from the summary.
+func NewJUnitReport(report Report, generatedAt time.Time) JUnitTestSuites {
+	suites := JUnitTestSuites{Name: "ci-signal-report", Suites: []JUnitTestSuite{}}
+	for _, reportData := range report {
+		if reportData.Name != testgridReport {
+			continue
+		}
+		for _, field := range reportData.Data {
+			suite := JUnitTestSuite{Name: field.Title, Timestamp: generatedAt.UTC().Format("2006-01-02T15:04:05"), Cases: []JUnitTestCase{}}
+			if field.Error != "" {
+				suite.Cases = append(suite.Cases, JUnitTestCase{Name: field.Title, ClassName: field.Title, Error: &JUnitMessage{Message: "could not be requested", Text: field.Error}})
+			}
+			// stale jobs that are failing or flaky as well are reported once, passing stale jobs are skipped
+			jobs := map[string]bool{}
+			staleJobs := map[string]bool{}
+			for _, record := range field.Records {
+				if record.ID == testgridReportDetails {
+					jobs[record.Title] = true
+				} else if record.ID == testgridReportStale {
+					staleJobs[record.Title] = true
+				}
+			}
+			for _, record := range field.Records {
+				switch record.ID {
+				case testgridReportSummary:
+					for _, job := range record.PassingJobs {
+						if !staleJobs[job] {
+							suite.Cases = append(suite.Cases, JUnitTestCase{Name: job, ClassName: field.Title})
+						}
+					}
+					for _, job := range record.FailingJobs {
+						if !jobs[job] {
+							suite.Cases = append(suite.Cases, JUnitTestCase{Name: job, ClassName: field.Title, Failure: &JUnitMessage{Message: "failing", Type: strings.ToLower(string(failing))}})
+						}
+					}
+					for _, job := range record.FlakyJobs {
+						if !jobs[job] {
+							suite.Cases = append(suite.Cases, JUnitTestCase{Name: job, ClassName: field.Title, SystemOut: strings.ToLower(string(flaky))})
+						}
+					}
+				case testgridReportDetails:
+					testCase := JUnitTestCase{Name: record.Title, ClassName: field.Title}
+					if record.Status == string(failing) {
+						testCase.Failure = &JUnitMessage{Message: junitFailureMessage(record), Type: strings.ToLower(record.Status), Text: junitFailureText(record)}
+					} else {
+						testCase.SystemOut = fmt.Sprintf("%s: %s (%s)", strings.ToLower(record.Status), junitFailureMessage(record), record.URL)
+					}
+					suite.Cases = append(suite.Cases, testCase)
+				case testgridReportStale:
+					if jobs[record.Title] {
+						continue
+					}
+					suite.Cases = append(suite.Cases, JUnitTestCase{Name: record.Title, ClassName: field.Title, Skipped: &JUnitMessage{Message: strings.Join(record.Notes, ", ")}})
+				}
+			}
+			for _, testCase := range suite.Cases {
+				suite.Tests++
+				if testCase.Failure != nil {
+					suite.Failures++
+				}
+				if testCase.Error != nil {
+					suite.Errors++
+				}
+				if testCase.Skipped != nil {
+					suite.Skipped++
+				}
+			}
+			suites.Tests += suite.Tests
+			suites.Failures += suite.Failures
+			suites.Errors += suite.Errors
+			suites.Skipped += suite.Skipped
+			suites.Suites = append(suites.Suites, suite)
+		}
+	}
+	return suites
+}
+
+// junitFailureMessage summarizes a job like '2 of 10 recent runs passed, 3 failing tests'
+func junitFailureMessage(record ReportDataRecord) string {
+	message := fmt.Sprintf("%d of %d recent runs passed", record.RecentPasses, record.RecentRuns)
+	if len(record.FailingTests) != 0 {
+		message += fmt.Sprintf(", %d failing tests", len(record.FailingTests))
+	}
+	return message
+}
+
+// junitFailureText lists the failing tests and the notes of a job
+func junitFailureText(record ReportDataRecord) string {
+	lines := []string{record.URL}
+	for _, test := range record.FailingTests {
+		lines = append(lines, "- "+test)
+	}
+	for _, note := range record.Notes {
+		lines = append(lines, stripANSI(note))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// WriteJUnit writes the testgrid jobs of the report as junit xml
+func WriteJUnit(w io.Writer, report Report, generatedAt time.Time) error {
+	b, err := xml.MarshalIndent(NewJUnitReport(report, generatedAt), "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	if _, err := w.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return nil
+}
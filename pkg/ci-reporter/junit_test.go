@@ -0,0 +1,79 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewJUnitReport(t *testing.T) {
+	details := Report{{Name: testgridReport, Data: []ReportDataField{
+		{Title: "Master-Blocking", Records: []ReportDataRecord{
+			shortReport[0].Data[0].Records[0],
+			{ID: testgridReportDetails, Title: "kind-master-parallel", URL: "https://testgrid.k8s.io/sig-release-master-blocking#kind-master-parallel", Status: string(failing), RecentPasses: 2, RecentRuns: 10, FailingTests: []string{"Kubernetes e2e suite.[It] [sig-storage] volume metrics"}},
+			{ID: testgridReportStale, Title: "build-master", Notes: []string{"not run for 3d"}},
+		}},
+	}}}
+	tests := []struct {
+		name   string
+		report Report
+		want   []JUnitTestSuite
+	}{
+		{
+			name:   "summary only",
+			report: Report{shortReport[0]},
+			want: []JUnitTestSuite{
+				{Name: "Master-Blocking", Tests: 3, Failures: 1, Cases: []JUnitTestCase{
+					{Name: "build-master", ClassName: "Master-Blocking"},
+					{Name: "kind-master-parallel", ClassName: "Master-Blocking", Failure: &JUnitMessage{Message: "failing", Type: "failing"}},
+					{Name: "gce-cos-master-default", ClassName: "Master-Blocking", SystemOut: "flaky"},
+				}},
+				{Name: "Master-Informing", Tests: 1, Cases: []JUnitTestCase{
+					{Name: "gce-master-scale", ClassName: "Master-Informing", SystemOut: "flaky"},
+				}},
+			},
+		},
+		{
+			name:   "details",
+			report: details,
+			want: []JUnitTestSuite{
+				{Name: "Master-Blocking", Tests: 3, Failures: 1, Skipped: 1, Cases: []JUnitTestCase{
+					{Name: "gce-cos-master-default", ClassName: "Master-Blocking", SystemOut: "flaky"},
+					{Name: "kind-master-parallel", ClassName: "Master-Blocking", Failure: &JUnitMessage{
+						Message: "2 of 10 recent runs passed, 1 failing tests",
+						Type:    "failing",
+						Text:    "https://testgrid.k8s.io/sig-release-master-blocking#kind-master-parallel\n- Kubernetes e2e suite.[It] [sig-storage] volume metrics",
+					}},
+					{Name: "build-master", ClassName: "Master-Blocking", Skipped: &JUnitMessage{Message: "not run for 3d"}},
+				}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewJUnitReport(tt.report, time.Date(2021, 11, 2, 9, 30, 0, 0, time.UTC))
+			for i := range got.Suites {
+				got.Suites[i].Timestamp = ""
+			}
+			if !reflect.DeepEqual(got.Suites, tt.want) {
+				t.Errorf("NewJUnitReport() = %+v, want %+v", got.Suites, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metricsFamily a prometheus metric with all its samples
+type metricsFamily struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []metricsSample
+}
+
+// metricsSample a single value of a metric, labels are given as key value pairs
+type metricsSample struct {
+	Labels []string
+	Value  float64
+}
+
+func (f *metricsFamily) add(value float64, labels ...string) {
+	f.Samples = append(f.Samples, metricsSample{Labels: labels, Value: value})
+}
+
+// WriteMetrics writes the report in the prometheus text exposition format (usable for /metrics and the
+// node exporter textfile collector). fetchStats is optional.
+func WriteMetrics(w io.Writer, report Report, generatedAt time.Time, fetchStats *FetchStats) error {
+	generated := &metricsFamily{Name: "ci_signal_report_generated_timestamp_seconds", Help: "Time the report data has been requested at", Type: "gauge"}
+	complete := &metricsFamily{Name: "ci_signal_report_complete", Help: "1 if all data of a report could be requested", Type: "gauge"}
+	jobs := &metricsFamily{Name: "ci_signal_testgrid_jobs", Help: "Number of testgrid jobs by dashboard and status", Type: "gauge"}
+	failingTests := &metricsFamily{Name: "ci_signal_testgrid_job_failing_tests", Help: "Number of failing tests of a testgrid job", Type: "gauge"}
+	jobSeverity := &metricsFamily{Name: "ci_signal_testgrid_job_severity", Help: "Severity of a failing, flaky or stale testgrid job (1 light ... 3 high)", Type: "gauge"}
+	failingFor := &metricsFamily{Name: "ci_signal_testgrid_job_failing_seconds", Help: "Duration a testgrid job is failing for", Type: "gauge"}
+	issuesByLabel := &metricsFamily{Name: "ci_signal_github_open_issues_by_label", Help: "Number of open issues by label", Type: "gauge"}
+	issuesByKind := &metricsFamily{Name: "ci_signal_github_open_issues_by_kind", Help: "Number of open issues by kind", Type: "gauge"}
+	issuesBySig := &metricsFamily{Name: "ci_signal_github_open_issues_by_sig", Help: "Number of open issues by sig", Type: "gauge"}
+	issueAge := &metricsFamily{Name: "ci_signal_github_issue_age_seconds", Help: "Age of an open issue", Type: "gauge"}
+
+	generated.add(float64(generatedAt.Unix()))
+	labelCounts := map[string]int{}
+	kindCounts := map[string]int{}
+	sigCounts := map[string]int{}
+	for _, reportData := range report {
+		if reportData.Complete() {
+			complete.add(1, "report", reportData.Name)
+		} else {
+			complete.add(0, "report", reportData.Name)
+		}
+		for _, field := range reportData.Data {
+			for _, record := range field.Records {
+				switch reportData.Name {
+				case testgridReport:
+					if record.ID == testgridReportSummary {
+						for _, status := range sortedKeys(record.Counts) {
+							if status != strings.ToLower(string(total)) {
+								jobs.add(float64(record.Counts[status]), "dashboard", field.Title, "status", status)
+							}
+						}
+						continue
+					}
+					status := strings.ToLower(record.Status)
+					jobSeverity.add(float64(record.Severity), "dashboard", field.Title, "job", record.Title, "status", status)
+					if record.ID == testgridReportDetails {
+						failingTests.add(float64(len(record.FailingTests)), "dashboard", field.Title, "job", record.Title)
+						if record.FailingSince != nil {
+							failingFor.add(generatedAt.Sub(*record.FailingSince).Seconds(), "dashboard", field.Title, "job", record.Title)
+						}
+					}
+				case githubReport:
+					for _, label := range record.Labels {
+						labelCounts[label]++
+						if strings.HasPrefix(label, "kind/") {
+							kindCounts[strings.TrimPrefix(label, "kind/")]++
+						}
+					}
+					sigs := record.Sigs
+					if len(sigs) == 0 {
+						sigs = []string{noSig}
+					}
+					for _, sig := range sigs {
+						sigCounts[sig]++
+					}
+					if record.CreatedAt != nil {
+						// issue numbers are unique per repository only
+						repo := record.Repo
+						if repo == "" {
+							repo = defaultGithubRepo
+						}
+						issueAge.add(generatedAt.Sub(*record.CreatedAt).Seconds(), "repo", repo, "number", fmt.Sprintf("%d", record.ID), "sig", strings.Join(sigs, ","))
+					}
+				}
+			}
+		}
+	}
+	for _, label := range sortedKeys(labelCounts) {
+		issuesByLabel.add(float64(labelCounts[label]), "label", label)
+	}
+	for _, kind := range sortedKeys(kindCounts) {
+		issuesByKind.add(float64(kindCounts[kind]), "kind", kind)
+	}
+	for _, sig := range sortedKeys(sigCounts) {
+		issuesBySig.add(float64(sigCounts[sig]), "sig", sig)
+	}
+
+	families := []*metricsFamily{generated, complete, jobs, failingTests, jobSeverity, failingFor, issuesByLabel, issuesByKind, issuesBySig, issueAge}
+	if fetchStats != nil {
+		requests := &metricsFamily{Name: "ci_signal_fetch_requests_total", Help: "Number of http requests sent to github and testgrid", Type: "counter"}
+		requests.add(float64(fetchStats.Requests))
+		failures := &metricsFamily{Name: "ci_signal_fetch_failures_total", Help: "Number of http requests that failed after all retries", Type: "counter"}
+		failures.add(float64(fetchStats.Failures))
+		received := &metricsFamily{Name: "ci_signal_fetch_received_bytes_total", Help: "Number of bytes received from github and testgrid", Type: "counter"}
+		received.add(float64(fetchStats.Bytes))
+		families = append(families, requests, failures, received)
+	}
+
+	for _, family := range families {
+		if len(family.Samples) == 0 {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", family.Name, family.Help, family.Name, family.Type); err != nil {
+			return err
+		}
+		for _, sample := range family.Samples {
+			if _, err := fmt.Fprintf(w, "%s%s %s\n", family.Name, formatMetricLabels(sample.Labels), strconv.FormatFloat(sample.Value, 'f', -1, 64)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteMetricsFile writes the metrics atomically, so the textfile collector never reads a partial file
+func WriteMetricsFile(path string, report Report, generatedAt time.Time, fetchStats *FetchStats) error {
+	var buf bytes.Buffer
+	if err := WriteMetrics(&buf, report, generatedAt, fetchStats); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, buf.Bytes())
+}
+
+// writeFileAtomic writes data to a temporary file that replaces path, so readers never see a partial file
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// formatMetricLabels formats key value pairs as prometheus labels ('{key="value"}')
+func formatMetricLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	pairs := []string{}
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", labels[i], metricLabelEscaper.Replace(labels[i+1])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var metricLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// sortedKeys returns the keys of a map in sorted order
+func sortedKeys(m map[string]int) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
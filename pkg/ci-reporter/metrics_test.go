@@ -0,0 +1,160 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// metricsGeneratedAt fixed time the metrics report has been requested at
+var metricsGeneratedAt = time.Date(2021, time.November, 1, 12, 0, 0, 0, time.UTC)
+
+// metricsReport report with a failing, a flaky and a stale job, an incomplete dashboard and issues of two
+// repositories
+func metricsReport() Report {
+	failingSince := metricsGeneratedAt.Add(-36 * time.Hour)
+	createdAt := metricsGeneratedAt.Add(-10 * 24 * time.Hour)
+	return Report{
+		{Name: testgridReport, Data: []ReportDataField{
+			{Title: "Master-Blocking", Records: []ReportDataRecord{
+				{ID: testgridReportSummary, Counts: map[string]int{"total": 4, "passing": 1, "failing": 1, "flaky": 1, "stale": 1}},
+				{ID: testgridReportDetails, Title: "ci-kubernetes-e2e-gci-gce", Status: string(failing), Severity: HighSeverity, FailingSince: &failingSince, FailingTests: []string{"a", "b"}},
+				{ID: testgridReportDetails, Title: "ci-kubernetes-unit", Status: string(flaky), Severity: LightSeverity},
+				{ID: testgridReportStale, Title: "ci-kubernetes-build", Status: string(stale), Severity: MediumSeverity},
+			}},
+			{Title: "Master-Informing", Records: []ReportDataRecord{}, Error: "502 Bad Gateway"},
+		}},
+		{Name: githubReport, Data: []ReportDataField{{Records: []ReportDataRecord{
+			{ID: 105965, Labels: []string{"kind/failing-test", "sig/storage"}, Sigs: []string{"storage"}, CreatedAt: &createdAt},
+			{ID: 2312, Repo: "kubernetes-sigs/kind", Labels: []string{"kind/flake"}, CreatedAt: &createdAt},
+			// label values are escaped
+			{ID: 106139, Labels: []string{`kind/flake`, `area/"e2e"`}, Sigs: []string{"node", "storage"}},
+		}}}},
+	}
+}
+
+const wantMetrics = `# HELP ci_signal_report_generated_timestamp_seconds Time the report data has been requested at
+# TYPE ci_signal_report_generated_timestamp_seconds gauge
+ci_signal_report_generated_timestamp_seconds 1635768000
+# HELP ci_signal_report_complete 1 if all data of a report could be requested
+# TYPE ci_signal_report_complete gauge
+ci_signal_report_complete{report="testgrid"} 0
+ci_signal_report_complete{report="github"} 1
+# HELP ci_signal_testgrid_jobs Number of testgrid jobs by dashboard and status
+# TYPE ci_signal_testgrid_jobs gauge
+ci_signal_testgrid_jobs{dashboard="Master-Blocking",status="failing"} 1
+ci_signal_testgrid_jobs{dashboard="Master-Blocking",status="flaky"} 1
+ci_signal_testgrid_jobs{dashboard="Master-Blocking",status="passing"} 1
+ci_signal_testgrid_jobs{dashboard="Master-Blocking",status="stale"} 1
+# HELP ci_signal_testgrid_job_failing_tests Number of failing tests of a testgrid job
+# TYPE ci_signal_testgrid_job_failing_tests gauge
+ci_signal_testgrid_job_failing_tests{dashboard="Master-Blocking",job="ci-kubernetes-e2e-gci-gce"} 2
+ci_signal_testgrid_job_failing_tests{dashboard="Master-Blocking",job="ci-kubernetes-unit"} 0
+# HELP ci_signal_testgrid_job_severity Severity of a failing, flaky or stale testgrid job (1 light ... 3 high)
+# TYPE ci_signal_testgrid_job_severity gauge
+ci_signal_testgrid_job_severity{dashboard="Master-Blocking",job="ci-kubernetes-e2e-gci-gce",status="failing"} 3
+ci_signal_testgrid_job_severity{dashboard="Master-Blocking",job="ci-kubernetes-unit",status="flaky"} 1
+ci_signal_testgrid_job_severity{dashboard="Master-Blocking",job="ci-kubernetes-build",status="stale"} 2
+# HELP ci_signal_testgrid_job_failing_seconds Duration a testgrid job is failing for
+# TYPE ci_signal_testgrid_job_failing_seconds gauge
+ci_signal_testgrid_job_failing_seconds{dashboard="Master-Blocking",job="ci-kubernetes-e2e-gci-gce"} 129600
+# HELP ci_signal_github_open_issues_by_label Number of open issues by label
+# TYPE ci_signal_github_open_issues_by_label gauge
+ci_signal_github_open_issues_by_label{label="area/\"e2e\""} 1
+ci_signal_github_open_issues_by_label{label="kind/failing-test"} 1
+ci_signal_github_open_issues_by_label{label="kind/flake"} 2
+ci_signal_github_open_issues_by_label{label="sig/storage"} 1
+# HELP ci_signal_github_open_issues_by_kind Number of open issues by kind
+# TYPE ci_signal_github_open_issues_by_kind gauge
+ci_signal_github_open_issues_by_kind{kind="failing-test"} 1
+ci_signal_github_open_issues_by_kind{kind="flake"} 2
+# HELP ci_signal_github_open_issues_by_sig Number of open issues by sig
+# TYPE ci_signal_github_open_issues_by_sig gauge
+ci_signal_github_open_issues_by_sig{sig="node"} 1
+ci_signal_github_open_issues_by_sig{sig="none"} 1
+ci_signal_github_open_issues_by_sig{sig="storage"} 2
+# HELP ci_signal_github_issue_age_seconds Age of an open issue
+# TYPE ci_signal_github_issue_age_seconds gauge
+ci_signal_github_issue_age_seconds{repo="kubernetes/kubernetes",number="105965",sig="storage"} 864000
+ci_signal_github_issue_age_seconds{repo="kubernetes-sigs/kind",number="2312",sig="none"} 864000
+# HELP ci_signal_fetch_requests_total Number of http requests sent to github and testgrid
+# TYPE ci_signal_fetch_requests_total counter
+ci_signal_fetch_requests_total 42
+# HELP ci_signal_fetch_failures_total Number of http requests that failed after all retries
+# TYPE ci_signal_fetch_failures_total counter
+ci_signal_fetch_failures_total 1
+# HELP ci_signal_fetch_received_bytes_total Number of bytes received from github and testgrid
+# TYPE ci_signal_fetch_received_bytes_total counter
+ci_signal_fetch_received_bytes_total 123456
+`
+
+func TestWriteMetrics(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteMetrics(&b, metricsReport(), metricsGeneratedAt, &FetchStats{Requests: 42, Failures: 1, Bytes: 123456}); err != nil {
+		t.Fatalf("WriteMetrics() error = %v", err)
+	}
+	if got := b.String(); got != wantMetrics {
+		t.Errorf("WriteMetrics() =\n%s\nwant:\n%s", got, wantMetrics)
+	}
+}
+
+func TestWriteMetricsWithoutStats(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteMetrics(&b, Report{}, metricsGeneratedAt, nil); err != nil {
+		t.Fatalf("WriteMetrics() error = %v", err)
+	}
+	// families without samples are left out
+	want := "# HELP ci_signal_report_generated_timestamp_seconds Time the report data has been requested at\n# TYPE ci_signal_report_generated_timestamp_seconds gauge\nci_signal_report_generated_timestamp_seconds 1635768000\n"
+	if got := b.String(); got != want {
+		t.Errorf("WriteMetrics() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteMetricsFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ci-signal.prom")
+	if err := ioutil.WriteFile(path, []byte("old"), 0o600); err != nil {
+		t.Fatalf("could not write %s: %v", path, err)
+	}
+	if err := WriteMetricsFile(path, metricsReport(), metricsGeneratedAt, &FetchStats{Requests: 42, Failures: 1, Bytes: 123456}); err != nil {
+		t.Fatalf("WriteMetricsFile() error = %v", err)
+	}
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("could not read %s: %v", path, err)
+	}
+	if string(got) != wantMetrics {
+		t.Errorf("WriteMetricsFile() wrote\n%s\nwant:\n%s", got, wantMetrics)
+	}
+	// the textfile collector has to be able to read the file
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o644 {
+		t.Errorf("mode of %s = %v (%v), want 0644", path, info.Mode().Perm(), err)
+	}
+	// the temporary file has been renamed
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("got %d files in %s, want the metrics file only", len(files), dir)
+	}
+
+	if err := WriteMetricsFile(filepath.Join(dir, "missing", "ci-signal.prom"), metricsReport(), metricsGeneratedAt, nil); err == nil {
+		t.Error("WriteMetricsFile() into a missing directory returned no error")
+	}
+}
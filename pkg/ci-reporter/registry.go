@@ -0,0 +1,132 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ReporterFactory creates a new reporter instance
+type ReporterFactory func() CIReport
+
+// registeredReporter a reporter that can be selected via -report
+type registeredReporter struct {
+	Name        string
+	Description string
+	New         ReporterFactory
+}
+
+var (
+	reporterRegistryMu sync.Mutex
+	reporterRegistry   = map[string]registeredReporter{}
+)
+
+// RegisterReporter makes a reporter available under the given name. Reporters of other packages can be added by
+// calling this function in an init function before SetMeta is called. It panics if a name is registered twice.
+func RegisterReporter(name string, description string, factory ReporterFactory) {
+	reporterRegistryMu.Lock()
+	defer reporterRegistryMu.Unlock()
+	if name == "" || strings.HasPrefix(name, "-") || strings.ContainsAny(name, ", ") {
+		panic(fmt.Sprintf("cireporter: invalid reporter name %q", name))
+	}
+	if factory == nil {
+		panic(fmt.Sprintf("cireporter: reporter %s registered without a factory", name))
+	}
+	if _, exists := reporterRegistry[name]; exists {
+		panic(fmt.Sprintf("cireporter: reporter %s registered twice", name))
+	}
+	reporterRegistry[name] = registeredReporter{Name: name, Description: description, New: factory}
+}
+
+// registeredReporters returns all registered reporters sorted by name
+func registeredReporters() []registeredReporter {
+	reporterRegistryMu.Lock()
+	defer reporterRegistryMu.Unlock()
+	reporters := []registeredReporter{}
+	for _, r := range reporterRegistry {
+		reporters = append(reporters, r)
+	}
+	sort.Slice(reporters, func(i, j int) bool {
+		return reporters[i].Name < reporters[j].Name
+	})
+	return reporters
+}
+
+// reporterUsage describes the registered reporters for -h
+func reporterUsage() string {
+	descriptions := []string{}
+	for _, r := range registeredReporters() {
+		descriptions = append(descriptions, fmt.Sprintf("'%s' (%s)", r.Name, r.Description))
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+// selectReporters resolves the value of -report into reporter names
+// - "" selects all reporters
+// - "github,testgrid" selects the listed reporters
+// - "-github" selects all reporters except the excluded ones
+func selectReporters(input string) ([]string, error) {
+	available := []string{}
+	for _, r := range registeredReporters() {
+		available = append(available, r.Name)
+	}
+	included := []string{}
+	excluded := []string{}
+	for _, e := range strings.Split(input, ",") {
+		name := strings.TrimSpace(e)
+		if name == "" {
+			continue
+		}
+		exclude := strings.HasPrefix(name, "-")
+		name = strings.TrimPrefix(name, "-")
+		if !containsString(available, name) {
+			return nil, fmt.Errorf("reporter %q does not match options [%s]", name, strings.Join(available, ", "))
+		}
+		if exclude {
+			excluded = append(excluded, name)
+		} else {
+			included = append(included, name)
+		}
+	}
+	if len(included) == 0 {
+		included = available
+	}
+	selected := []string{}
+	for _, name := range available {
+		if containsString(included, name) && !containsString(excluded, name) {
+			selected = append(selected, name)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no reporter selected by %q", input)
+	}
+	return selected, nil
+}
+
+// GetReporters used to get reporters that implement methods like RequestData and Print
+func (m Meta) GetReporters() []CIReport {
+	reporterRegistryMu.Lock()
+	defer reporterRegistryMu.Unlock()
+	reporters := []CIReport{}
+	for _, name := range m.Flags.Reports {
+		reporters = append(reporters, reporterRegistry[name].New())
+	}
+	return reporters
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// stubReport reporter of another package, it reports the data of request (or its name only)
+type stubReport struct {
+	name       string
+	request    func() ReportData
+	reportData ReportData
+}
+
+func (r *stubReport) RequestData(ctx context.Context, meta Meta, wg *sync.WaitGroup) ReportData {
+	defer wg.Done()
+	if r.request != nil {
+		return r.request()
+	}
+	return ReportData{Name: r.name}
+}
+
+func (r *stubReport) Print(meta Meta, reportData ReportData) {}
+
+func (r *stubReport) PutData(reportData ReportData) {
+	r.reportData = reportData
+}
+
+func (r stubReport) GetData() ReportData {
+	return r.reportData
+}
+
+// registerStubReporter registers a stub reporter for the test that reports the data of request (nil reports the
+// name only), it is removed from the registry afterwards
+func registerStubReporter(t *testing.T, name string, request func() ReportData) {
+	t.Helper()
+	RegisterReporter(name, "stub "+name, func() CIReport { return &stubReport{name: name, request: request} })
+	t.Cleanup(func() {
+		reporterRegistryMu.Lock()
+		defer reporterRegistryMu.Unlock()
+		delete(reporterRegistry, name)
+	})
+}
+
+// expectPanic fails the test if f does not panic with a message that contains want
+func expectPanic(t *testing.T, want string, f func()) {
+	t.Helper()
+	defer func() {
+		t.Helper()
+		r := recover()
+		if msg, _ := r.(string); !strings.Contains(msg, want) {
+			t.Errorf("got panic %v, want %q", r, want)
+		}
+	}()
+	f()
+}
+
+func TestRegisterReporter(t *testing.T) {
+	// reporters are listed by name whatever order the init functions of their packages run in
+	registerStubReporter(t, "zuul", nil)
+	registerStubReporter(t, "prow", nil)
+	names := []string{}
+	for _, r := range registeredReporters() {
+		names = append(names, r.Name)
+	}
+	if want := []string{githubReport, "prow", testgridReport, "zuul"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("registeredReporters() = %q, want %q", names, want)
+	}
+	if usage := reporterUsage(); !strings.Contains(usage, "'prow' (stub prow)") || !strings.Contains(usage, "'zuul' (stub zuul)") {
+		t.Errorf("reporterUsage() = %q, want the stub reporters", usage)
+	}
+
+	meta := Meta{Flags: metaFlags{Reports: []string{"zuul", githubReport}}}
+	reporters := meta.GetReporters()
+	if len(reporters) != 2 {
+		t.Fatalf("GetReporters() returned %d reporters, want 2", len(reporters))
+	}
+	if stub, ok := reporters[0].(*stubReport); !ok || stub.name != "zuul" {
+		t.Errorf("GetReporters()[0] = %#v, want the zuul stub", reporters[0])
+	}
+	if _, ok := reporters[1].(*GithubReport); !ok {
+		t.Errorf("GetReporters()[1] = %#v, want a github report", reporters[1])
+	}
+	// every call creates new reporters
+	if meta.GetReporters()[0] == reporters[0] {
+		t.Error("GetReporters() returned the same reporter twice")
+	}
+}
+
+func TestRegisterReporterInvalid(t *testing.T) {
+	registerStubReporter(t, "prow", nil)
+	expectPanic(t, "reporter prow registered twice", func() {
+		RegisterReporter("prow", "another prow", func() CIReport { return &stubReport{} })
+	})
+	expectPanic(t, "reporter github registered twice", func() {
+		RegisterReporter(githubReport, "another github", func() CIReport { return &stubReport{} })
+	})
+	for _, name := range []string{"", "-prow", "prow,zuul", "prow zuul"} {
+		expectPanic(t, "invalid reporter name", func() {
+			RegisterReporter(name, "invalid", func() CIReport { return &stubReport{} })
+		})
+	}
+	expectPanic(t, "reporter zuul registered without a factory", func() {
+		RegisterReporter("zuul", "no factory", nil)
+	})
+	// the registry is unchanged
+	if got := registeredReporters(); len(got) != 3 || got[1].Description != "stub prow" {
+		t.Errorf("registeredReporters() = %+v, want github, prow and testgrid", got)
+	}
+}
+
+func TestSelectReporters(t *testing.T) {
+	registerStubReporter(t, "prow", nil)
+	tests := []struct {
+		input   string
+		want    []string
+		wantErr string
+	}{
+		{input: "", want: []string{githubReport, "prow", testgridReport}},
+		{input: " , ", want: []string{githubReport, "prow", testgridReport}},
+		// the selected reporters are sorted by name
+		{input: "testgrid,prow", want: []string{"prow", testgridReport}},
+		{input: " github , github", want: []string{githubReport}},
+		{input: "-github", want: []string{"prow", testgridReport}},
+		{input: "-github,-prow", want: []string{testgridReport}},
+		{input: "prow,testgrid,-testgrid", want: []string{"prow"}},
+		{input: "jenkins", wantErr: `reporter "jenkins" does not match options [github, prow, testgrid]`},
+		{input: "-jenkins", wantErr: `reporter "jenkins" does not match options`},
+		{input: "Github", wantErr: `reporter "Github" does not match options`},
+		{input: "-github,-prow,-testgrid", wantErr: `no reporter selected by "-github,-prow,-testgrid"`},
+		{input: "github,-github", wantErr: "no reporter selected"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := selectReporters(tt.input)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("selectReporters() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("selectReporters() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectReporters() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// releaseVersionAuto can be passed via -v to detect the supported release versions
+	releaseVersionAuto = "auto"
+	// supportedReleaseVersions number of minor releases that are maintained in parallel
+	supportedReleaseVersions = 3
+)
+
+// Release versions have the form <major>.<minor> without leading zeros (e.g. 1.22)
+var releaseVersionRegex = regexp.MustCompile(`^(0|[1-9]\d*)\.(0|[1-9]\d*)$`)
+
+// Release branches of kubernetes/kubernetes have the form release-<major>.<minor>
+var releaseBranchRegex = regexp.MustCompile(`^release-((0|[1-9]\d*)\.(0|[1-9]\d*))$`)
+
+// releaseVersion a kubernetes minor release like 1.22
+type releaseVersion struct {
+	Major int
+	Minor int
+}
+
+func (v releaseVersion) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// parseReleaseVersion validates a release version like "1.22" strictly
+func parseReleaseVersion(s string) (releaseVersion, error) {
+	match := releaseVersionRegex.FindStringSubmatch(s)
+	if match == nil {
+		return releaseVersion{}, fmt.Errorf("release version %q is not valid, expected <major>.<minor> like '1.22'", s)
+	}
+	major, err := strconv.Atoi(match[1])
+	if err != nil {
+		return releaseVersion{}, fmt.Errorf("release version %q has an invalid major version: %v", s, err)
+	}
+	minor, err := strconv.Atoi(match[2])
+	if err != nil {
+		return releaseVersion{}, fmt.Errorf("release version %q has an invalid minor version: %v", s, err)
+	}
+	return releaseVersion{Major: major, Minor: minor}, nil
+}
+
+// This function is used to split release version input ("1.22, 1.21" => ["1.22", "1.21"])
+func splitReleaseVersionInput(input string) ([]string, error) {
+	releaseVersions := []string{}
+	seen := map[string]bool{}
+	for _, e := range strings.Split(input, ",") {
+		trimStr := strings.TrimSpace(e)
+		if trimStr == "" {
+			continue
+		}
+		v, err := parseReleaseVersion(trimStr)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[v.String()] {
+			seen[v.String()] = true
+			releaseVersions = append(releaseVersions, v.String())
+		}
+	}
+	return releaseVersions, nil
+}
+
+// detectReleaseVersions looks up the release branches of kubernetes/kubernetes and returns the latest
+// supported release versions that have a blocking dashboard on testgrid (newest first)
+func detectReleaseVersions(ctx context.Context, fetcher *Fetcher, authToken string) ([]string, error) {
+	versions := []releaseVersion{}
+	for page := 1; ; page++ {
+		url := fmt.Sprintf("https://api.github.com/repos/kubernetes/kubernetes/branches?per_page=100&page=%d", page)
+		resp, err := fetcher.Get(ctx, url, githubHeader(authToken))
+		if err != nil {
+			return nil, fmt.Errorf("could not list kubernetes/kubernetes branches: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("could not list kubernetes/kubernetes branches: %s returned %s", url, resp.Status)
+		}
+		branches := []struct {
+			Name string `json:"name"`
+		}{}
+		if err := json.Unmarshal(resp.Body, &branches); err != nil {
+			return nil, fmt.Errorf("could not unmarshal kubernetes/kubernetes branches: %v", err)
+		}
+		if len(branches) == 0 {
+			break
+		}
+		for _, branch := range branches {
+			match := releaseBranchRegex.FindStringSubmatch(branch.Name)
+			if match == nil {
+				continue
+			}
+			v, err := parseReleaseVersion(match[1])
+			if err != nil {
+				return nil, err
+			}
+			versions = append(versions, v)
+		}
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		if versions[i].Major != versions[j].Major {
+			return versions[i].Major > versions[j].Major
+		}
+		return versions[i].Minor > versions[j].Minor
+	})
+
+	// A freshly cut release branch may not have testgrid dashboards yet, those are skipped
+	releaseVersions := []string{}
+	for _, v := range versions {
+		if len(releaseVersions) == supportedReleaseVersions {
+			break
+		}
+		exists, err := testgridDashboardExists(ctx, fetcher, fmt.Sprintf("sig-release-%s-blocking", v))
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			releaseVersions = append(releaseVersions, v.String())
+		}
+	}
+	if len(releaseVersions) == 0 {
+		return nil, fmt.Errorf("could not detect any release version with a testgrid dashboard")
+	}
+	return releaseVersions, nil
+}
+
+// testgridDashboardExists checks if the summary of a testgrid dashboard can be requested
+func testgridDashboardExists(ctx context.Context, fetcher *Fetcher, dashboard string) (bool, error) {
+	resp, err := fetcher.Get(ctx, fmt.Sprintf("https://testgrid.k8s.io/%s/summary", dashboard), nil)
+	if err != nil {
+		return false, fmt.Errorf("could not request testgrid dashboard %s: %v", dashboard, err)
+	}
+	return resp.StatusCode == http.StatusOK, nil
+}
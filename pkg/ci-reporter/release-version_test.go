@@ -0,0 +1,129 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseReleaseVersion(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    releaseVersion
+		wantErr bool
+	}{
+		{input: "1.22", want: releaseVersion{Major: 1, Minor: 22}},
+		{input: "0.0", want: releaseVersion{Major: 0, Minor: 0}},
+		{input: "2.10", want: releaseVersion{Major: 2, Minor: 10}},
+		{input: "1.022", wantErr: true},
+		{input: "01.22", wantErr: true},
+		{input: "1.22.3", wantErr: true},
+		{input: "v1.22", wantErr: true},
+		{input: "1", wantErr: true},
+		{input: "1.", wantErr: true},
+		{input: ".22", wantErr: true},
+		{input: " 1.22", wantErr: true},
+		{input: "1.x", wantErr: true},
+		{input: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseReleaseVersion(tt.input)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "expected <major>.<minor>") {
+					t.Errorf("parseReleaseVersion() error = %v, want an invalid release version", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseReleaseVersion() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("parseReleaseVersion() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitReleaseVersionInput(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []string
+		wantErr string
+	}{
+		{input: "", want: []string{}},
+		{input: "1.22", want: []string{"1.22"}},
+		{input: "1.22, 1.21,1.20", want: []string{"1.22", "1.21", "1.20"}},
+		{input: ",1.22,, ,1.21,", want: []string{"1.22", "1.21"}},
+		{input: "1.22,1.21,1.22", want: []string{"1.22", "1.21"}},
+		{input: "1.22, 1.22.3", wantErr: `"1.22.3"`},
+		{input: "1.22,1.021", wantErr: `"1.021"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := splitReleaseVersionInput(tt.input)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("splitReleaseVersionInput() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("splitReleaseVersionInput() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitReleaseVersionInput() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReleaseBranchRegex(t *testing.T) {
+	tests := []struct {
+		branch string
+		want   string
+	}{
+		{branch: "release-1.22", want: "1.22"},
+		{branch: "release-1.9", want: "1.9"},
+		{branch: "release-1.22.3", want: ""},
+		{branch: "release-1.022", want: ""},
+		{branch: "release-1.22-beta", want: ""},
+		{branch: "feature-release-1.22", want: ""},
+		{branch: "release-1.22-alpha.1", want: ""},
+		{branch: "master", want: ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if match := releaseBranchRegex.FindStringSubmatch(tt.branch); match != nil {
+			got = match[1]
+		}
+		if got != tt.want {
+			t.Errorf("releaseBranchRegex of %q = %q, want %q", tt.branch, got, tt.want)
+		}
+	}
+}
+
+func TestDetectReleaseVersionsCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := detectReleaseVersions(ctx, NewFetcher(FetcherOptions{}), ""); err == nil {
+		t.Error("detectReleaseVersions() with a canceled context returned no error")
+	}
+}
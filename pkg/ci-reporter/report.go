@@ -0,0 +1,99 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Output formats that can be selected via -format
+const (
+	formatText  = "text"
+	formatJSON  = "json"
+	formatEmail = "email"
+	formatCSV   = "csv"
+	formatTSV   = "tsv"
+	formatJUnit = "junit"
+)
+
+var formatOptions = []string{formatText, formatJSON, formatEmail, formatCSV, formatTSV, formatJUnit}
+
+// RequestReport runs the given reporters and collects their data, the report is partial if ctx is done early
+func RequestReport(ctx context.Context, meta Meta, cireporters []CIReport) Report {
+	if meta.Flags.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, meta.Flags.Timeout)
+		defer cancel()
+	}
+	report := Report{}
+	var wg sync.WaitGroup
+	for _, r := range cireporters {
+		wg.Add(1)
+		report = append(report, r.RequestData(ctx, meta, &wg))
+	}
+	wg.Wait()
+	return report
+}
+
+// Complete checks if all data of the report could be requested
+func (r ReportData) Complete() bool {
+	for _, field := range r.Data {
+		if field.Error != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// PrintReport prints the report data of the given reporters in the format selected via flags (or the -template)
+func PrintReport(meta Meta, cireporters []CIReport, report Report) error {
+	if meta.Flags.Template != "" {
+		if err := RenderReport(os.Stdout, meta, report, time.Now()); err != nil {
+			return fmt.Errorf("could not render the report with template %s: %v", meta.Flags.Template, err)
+		}
+	} else if meta.Flags.Format == formatEmail {
+		message, err := BuildEmail(meta, report, time.Now())
+		if err != nil {
+			return fmt.Errorf("could not build the email: %v", err)
+		}
+		os.Stdout.Write(message)
+	} else if meta.Flags.Format == formatCSV || meta.Flags.Format == formatTSV {
+		if err := WriteCSV(meta, report); err != nil {
+			return fmt.Errorf("could not write the report as %s: %v", meta.Flags.Format, err)
+		}
+	} else if meta.Flags.Format == formatJUnit {
+		if err := WriteJUnit(os.Stdout, report, time.Now()); err != nil {
+			return fmt.Errorf("could not write the report as junit: %v", err)
+		}
+	} else if meta.Flags.GroupBy != "" {
+		PrintGroupedBySig(meta, report)
+	} else if meta.Flags.JSONOut {
+		report.PrintJSON()
+	} else {
+		for _, r := range cireporters {
+			reportData := r.GetData()
+			fmt.Printf("\n%s REPORT\n", strings.ToUpper(reportData.Name))
+			r.Print(meta, reportData)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"context"
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server serves the report over http and refreshes it periodically (ci-reporter serve)
+type Server struct {
+	meta Meta
+
+	mu sync.RWMutex
+	// data last complete data of every report, keyed by report name
+	data map[string]ServedReportData
+	// lastRefresh time of the last refresh attempt
+	lastRefresh time.Time
+}
+
+// ServedReportData report data and the time it has been requested
+type ServedReportData struct {
+	GeneratedAt time.Time  `json:"generated_at"`
+	Data        ReportData `json:"data"`
+	// Stale is set if the last refresh failed and older data is served
+	Stale bool `json:"stale"`
+	// Error of the last refresh if it failed
+	Error string `json:"error,omitempty"`
+}
+
+// ServedReport response of /api/report
+type ServedReport struct {
+	GeneratedAt time.Time          `json:"generated_at"`
+	Reports     []ServedReportData `json:"reports"`
+}
+
+// NewServer creates a Server
+func NewServer(meta Meta) *Server {
+	return &Server{meta: meta, data: map[string]ServedReportData{}}
+}
+
+// serverReadHeaderTimeout clients that do not send the request headers in time are disconnected
+const serverReadHeaderTimeout = 10 * time.Second
+
+// Run refreshes the report in the configured interval and serves it until ctx is done
+func (s *Server) Run(ctx context.Context) error {
+	httpServer := s.httpServer()
+
+	go func() {
+		s.refresh(ctx)
+		ticker := time.NewTicker(s.meta.Flags.ServeInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.refresh(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Error on shutting down the server: %v", err)
+		}
+	}()
+
+	log.Printf("Serving the report on %s, refreshed every %s", s.meta.Flags.ServeAddr, s.meta.Flags.ServeInterval)
+	if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// httpServer returns the http server of the report on -addr
+func (s *Server) httpServer() *http.Server {
+	return &http.Server{Addr: s.meta.Flags.ServeAddr, Handler: s.handler(), ReadHeaderTimeout: serverReadHeaderTimeout}
+}
+
+// handler routes the requests to the html report, the json api, the health check and the metrics
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleHTML)
+	mux.HandleFunc("/api/report", s.handleReport)
+	mux.HandleFunc("/api/report/", s.handleReporter)
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	return mux
+}
+
+// refresh requests the report, incomplete report data does not replace the last complete data of a report
+func (s *Server) refresh(ctx context.Context) {
+	report := RequestReport(ctx, s.meta, s.meta.GetReporters())
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastRefresh = now
+	for _, reportData := range report {
+		previous, exists := s.data[reportData.Name]
+		if reportData.Complete() || !exists {
+			s.data[reportData.Name] = ServedReportData{GeneratedAt: now, Data: reportData, Error: reportErrors(reportData)}
+			continue
+		}
+		previous.Stale = true
+		previous.Error = reportErrors(reportData)
+		s.data[reportData.Name] = previous
+		log.Printf("Refreshing the %s report failed, serving data of %s: %s", reportData.Name, previous.GeneratedAt.Format(time.RFC3339), previous.Error)
+	}
+}
+
+// reportErrors joins the errors of all fields of the report data
+func reportErrors(reportData ReportData) string {
+	errors := []string{}
+	for _, field := range reportData.Data {
+		if field.Error != "" {
+			errors = append(errors, field.Error)
+		}
+	}
+	return strings.Join(errors, ", ")
+}
+
+// served returns the served data in the order the reports are configured
+func (s *Server) served() ServedReport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	served := ServedReport{GeneratedAt: s.lastRefresh, Reports: []ServedReportData{}}
+	for _, name := range s.meta.Flags.Reports {
+		if data, ok := s.data[name]; ok {
+			served.Reports = append(served.Reports, data)
+		}
+	}
+	return served
+}
+
+func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
+	served := s.served()
+	if len(served.Reports) == 0 {
+		http.Error(w, "the report has not been requested yet", http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, served)
+}
+
+func (s *Server) handleReporter(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/report/"), "/")
+	if !containsString(s.meta.Flags.Reports, name) {
+		http.NotFound(w, r)
+		return
+	}
+	s.mu.RLock()
+	data, ok := s.data[name]
+	s.mu.RUnlock()
+	if !ok {
+		http.Error(w, "the report has not been requested yet", http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, data)
+}
+
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if len(s.served().Reports) == 0 {
+		http.Error(w, "the report has not been requested yet", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok"))
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	served := s.served()
+	report := Report{}
+	for _, data := range served.Reports {
+		report = append(report, data.Data)
+	}
+	stats := s.meta.Fetcher.Stats()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := WriteMetrics(w, report, served.GeneratedAt, &stats); err != nil {
+		log.Printf("Error on writing metrics: %v", err)
+	}
+}
+
+func (s *Server) handleHTML(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := serverHTMLTemplate.Execute(w, s.served()); err != nil {
+		log.Printf("Error on rendering the report: %v", err)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// Notes can contain terminal color codes that are removed before they are rendered as html
+var ansiRegex = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+func stripANSI(s string) string {
+	return ansiRegex.ReplaceAllString(s, "")
+}
+
+var serverHTMLTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"stripANSI":      stripANSI,
+	"upper":          strings.ToUpper,
+	"isSummary":      templateFuncs["isSummary"],
+	"issueReference": issueReference,
+	"isIssue": func(reportName string) bool {
+		return reportName == githubReport
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>CI signal report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+.stale, .error { color: #b00; }
+li { margin-bottom: 0.5em; }
+</style>
+</head>
+<body>
+<h1>CI signal report</h1>
+<p>Generated at {{ .GeneratedAt.UTC.Format "2006-01-02 15:04:05 MST" }}</p>
+{{- range .Reports }}
+{{- $name := .Data.Name }}
+<h2>{{ upper .Data.Name }} REPORT</h2>
+<p>Data from {{ .GeneratedAt.UTC.Format "2006-01-02 15:04:05 MST" }}{{ if .Stale }} <span class="stale">(stale, the last refresh failed: {{ .Error }})</span>{{ end }}</p>
+{{- range .Data.Data }}
+{{- if .Title }}<h3>{{ .Emoji }} {{ .Title }}</h3>{{ end }}
+{{- if .Error }}<p class="error">Could not be requested: {{ .Error }}</p>{{ end }}
+<ul>
+{{- range .Records }}
+{{- if isSummary $name . }}
+{{- range .Notes }}<li>{{ stripANSI . }}</li>{{ end }}
+{{- else }}
+<li>
+{{- if .Status }}{{ .Status }} {{ end }}{{ .Highlight }} <a href="{{ .URL }}">{{ if isIssue $name }}{{ issueReference . }} {{ end }}{{ .Title }}</a> {{ .Sig }}
+<ul>{{ range .Notes }}<li>{{ stripANSI . }}</li>{{ end }}</ul>
+</li>
+{{- end }}
+{{- end }}
+</ul>
+{{- end }}
+{{- end }}
+</body>
+</html>
+`))
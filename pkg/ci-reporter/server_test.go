@@ -0,0 +1,134 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// get requests path from the server and returns the status code, the content type and the body
+func get(t *testing.T, server *httptest.Server, path string) (int, string, string) {
+	t.Helper()
+	resp, err := http.Get(server.URL + path)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("GET %s: could not read the body: %v", path, err)
+	}
+	return resp.StatusCode, resp.Header.Get("Content-Type"), string(body)
+}
+
+func TestServer(t *testing.T) {
+	complete := ReportData{Name: "stub", Data: []ReportDataField{{Title: "Master-Blocking", Records: []ReportDataRecord{
+		{ID: testgridReportSummary, Notes: []string{"\x1b[31m1 jobs failing\x1b[0m"}, Counts: map[string]int{"failing": 1}},
+		{ID: testgridReportDetails, Title: "ci-kubernetes-e2e-gci-gce", URL: "https://testgrid.k8s.io/sig-release-master-blocking#ci-kubernetes-e2e-gci-gce", Status: string(failing)},
+	}}}}
+	incomplete := ReportData{Name: "stub", Data: []ReportDataField{{Title: "Master-Blocking", Records: []ReportDataRecord{}, Error: "502 Bad Gateway"}}}
+	next := complete
+	registerStubReporter(t, "stub", func() ReportData { return next })
+
+	s := NewServer(Meta{Flags: metaFlags{Reports: []string{"stub"}}, Fetcher: NewFetcher(FetcherOptions{})})
+	server := httptest.NewServer(s.handler())
+	defer server.Close()
+
+	// nothing is served before the first refresh
+	for _, tt := range []struct {
+		path   string
+		status int
+	}{
+		{path: "/api/report", status: http.StatusServiceUnavailable},
+		{path: "/api/report/stub", status: http.StatusServiceUnavailable},
+		{path: "/healthz", status: http.StatusServiceUnavailable},
+		{path: "/api/report/github", status: http.StatusNotFound},
+		{path: "/report", status: http.StatusNotFound},
+		{path: "/", status: http.StatusOK},
+		{path: "/metrics", status: http.StatusOK},
+	} {
+		if status, _, body := get(t, server, tt.path); status != tt.status {
+			t.Errorf("GET %s before the first refresh = %d (%s), want %d", tt.path, status, body, tt.status)
+		}
+	}
+
+	s.refresh(context.Background())
+	status, contentType, body := get(t, server, "/api/report")
+	if status != http.StatusOK || contentType != "application/json" {
+		t.Fatalf("GET /api/report = %d %s, want 200 application/json", status, contentType)
+	}
+	var served ServedReport
+	if err := json.Unmarshal([]byte(body), &served); err != nil {
+		t.Fatalf("could not unmarshal /api/report: %v", err)
+	}
+	if len(served.Reports) != 1 || served.Reports[0].Stale || !reflect.DeepEqual(served.Reports[0].Data, complete) {
+		t.Errorf("GET /api/report = %+v, want the complete data", served)
+	}
+	var reporter ServedReportData
+	if status, _, body := get(t, server, "/api/report/stub/"); status != http.StatusOK || json.Unmarshal([]byte(body), &reporter) != nil || !reflect.DeepEqual(reporter.Data, complete) {
+		t.Errorf("GET /api/report/stub/ = %d %s, want the complete data", status, body)
+	}
+	if status, _, body := get(t, server, "/healthz"); status != http.StatusOK || body != "ok" {
+		t.Errorf("GET /healthz = %d %s, want 200 ok", status, body)
+	}
+	status, contentType, body = get(t, server, "/")
+	if status != http.StatusOK || contentType != "text/html; charset=utf-8" {
+		t.Errorf("GET / = %d %s, want 200 text/html", status, contentType)
+	}
+	// the terminal colors of the notes are removed
+	for _, want := range []string{"<li>1 jobs failing</li>", `<a href="https://testgrid.k8s.io/sig-release-master-blocking#ci-kubernetes-e2e-gci-gce">ci-kubernetes-e2e-gci-gce</a>`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("GET / does not contain %s:\n%s", want, body)
+		}
+	}
+	status, contentType, body = get(t, server, "/metrics")
+	if status != http.StatusOK || !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("GET /metrics = %d %s, want 200 text/plain", status, contentType)
+	}
+	for _, want := range []string{`ci_signal_report_complete{report="stub"} 1`, "ci_signal_fetch_requests_total 0"} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("GET /metrics does not contain %s:\n%s", want, body)
+		}
+	}
+
+	// a failed refresh keeps serving the last complete data
+	next = incomplete
+	s.refresh(context.Background())
+	if status, _, body := get(t, server, "/api/report/stub"); status != http.StatusOK || json.Unmarshal([]byte(body), &reporter) != nil {
+		t.Fatalf("GET /api/report/stub = %d %s, want 200", status, body)
+	}
+	if !reporter.Stale || reporter.Error != "502 Bad Gateway" || !reflect.DeepEqual(reporter.Data, complete) {
+		t.Errorf("GET /api/report/stub after a failed refresh = %+v, want the complete data marked as stale", reporter)
+	}
+	if _, _, body := get(t, server, "/"); !strings.Contains(body, "stale, the last refresh failed: 502 Bad Gateway") {
+		t.Errorf("GET / after a failed refresh does not mark the data as stale:\n%s", body)
+	}
+}
+
+func TestServerHTTPServer(t *testing.T) {
+	httpServer := NewServer(Meta{Flags: metaFlags{ServeAddr: ":8080"}}).httpServer()
+	if httpServer.Addr != ":8080" || httpServer.ReadHeaderTimeout != serverReadHeaderTimeout {
+		t.Errorf("httpServer() addr %s, read header timeout %s, want :8080, %s", httpServer.Addr, httpServer.ReadHeaderTimeout, serverReadHeaderTimeout)
+	}
+}
@@ -0,0 +1,262 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Group options that can be set via -group-by
+const (
+	groupBySig = "sig"
+)
+
+// noSig is used to group records that could not be assigned to a sig
+const noSig = "none"
+
+// Github labels reference sigs like 'sig/cluster-lifecycle', testgrid test names like '[sig-cluster-lifecycle]'
+var sigRegex = regexp.MustCompile(`sig[/-]([a-zA-Z]+(?:-[a-zA-Z]+)*)`)
+
+// findSigs returns the normalized sig names referenced in a label or test name ('sig/storage', 'sig-storage' => 'storage')
+func findSigs(s string) []string {
+	sigs := []string{}
+	for _, match := range sigRegex.FindAllStringSubmatch(s, -1) {
+		sigs = append(sigs, normalizeSig(match[1]))
+	}
+	return sigs
+}
+
+// normalizeSig strips the prefix of a sig name ('sig/Storage', 'sig-storage', 'storage' => 'storage')
+func normalizeSig(sig string) string {
+	sig = strings.ToLower(strings.TrimSpace(sig))
+	sig = strings.TrimPrefix(sig, "sig/")
+	sig = strings.TrimPrefix(sig, "sig-")
+	return sig
+}
+
+// formatSigs formats normalized sig names to be printed ('[sig/node sig/storage]')
+func formatSigs(sigs []string) string {
+	formatted := []string{}
+	for _, sig := range sigs {
+		formatted = append(formatted, "sig/"+sig)
+	}
+	return fmt.Sprintf("%v", formatted)
+}
+
+// uniqueSortedStrings removes duplicates and sorts the list
+func uniqueSortedStrings(list []string) []string {
+	unique := []string{}
+	seen := map[string]bool{}
+	for _, e := range list {
+		if !seen[e] {
+			seen[e] = true
+			unique = append(unique, e)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
+
+// This function is used to split sig filter input ("sig/storage, node" => ["storage", "node"])
+func splitSigInput(input string) []string {
+	sigs := []string{}
+	for _, e := range strings.Split(input, ",") {
+		if sig := normalizeSig(e); sig != "" {
+			sigs = append(sigs, sig)
+		}
+	}
+	return uniqueSortedStrings(sigs)
+}
+
+// getTestSigs returns the sigs that own the given testgrid tests
+func getTestSigs(tests []test) []string {
+	sigs := []string{}
+	for _, t := range tests {
+		sigs = append(sigs, findSigs(t.TestName)...)
+	}
+	return uniqueSortedStrings(sigs)
+}
+
+// matchesSigFilter checks if a record belongs to one of the sigs set via -sig (every record matches if no sig is set)
+func matchesSigFilter(meta Meta, record ReportDataRecord) bool {
+	if len(meta.Flags.SigFilter) == 0 {
+		return true
+	}
+	for _, sig := range record.Sigs {
+		if containsString(meta.Flags.SigFilter, sig) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterRecordsBySig removes all records that do not belong to one of the sigs set via -sig
+func filterRecordsBySig(meta Meta, records []ReportDataRecord) []ReportDataRecord {
+	filtered := []ReportDataRecord{}
+	for _, record := range records {
+		if matchesSigFilter(meta, record) {
+			filtered = append(filtered, record)
+		}
+	}
+	return filtered
+}
+
+// SigReport contains the part of a report that a sig owns
+type SigReport struct {
+	// Sig normalized sig name like 'storage'
+	Sig string `json:"sig"`
+	// Jobs failing, flaky or stale testgrid jobs
+	Jobs []SigReportJob `json:"jobs"`
+	// Tests failing tests of the sig
+	Tests []string `json:"tests"`
+	// Issues open github issues
+	Issues []ReportDataRecord `json:"issues"`
+}
+
+// SigReportJob testgrid job and the dashboard it is part of
+type SigReportJob struct {
+	Dashboard string           `json:"dashboard"`
+	Job       ReportDataRecord `json:"job"`
+}
+
+// GroupBySig splits a report into sig reports (sorted by sig name, records without a sig are grouped last)
+func GroupBySig(report Report) []SigReport {
+	groups := map[string]*SigReport{}
+	getGroup := func(sig string) *SigReport {
+		if _, ok := groups[sig]; !ok {
+			groups[sig] = &SigReport{Sig: sig, Jobs: []SigReportJob{}, Tests: []string{}, Issues: []ReportDataRecord{}}
+		}
+		return groups[sig]
+	}
+	for _, reportData := range report {
+		for _, field := range reportData.Data {
+			for _, record := range field.Records {
+				sigs := record.Sigs
+				if len(sigs) == 0 {
+					sigs = []string{noSig}
+				}
+				switch reportData.Name {
+				case githubReport:
+					for _, sig := range sigs {
+						getGroup(sig).Issues = append(getGroup(sig).Issues, record)
+					}
+				case testgridReport:
+					if record.ID == testgridReportSummary {
+						continue
+					}
+					for _, sig := range sigs {
+						getGroup(sig).Jobs = append(getGroup(sig).Jobs, SigReportJob{Dashboard: field.Title, Job: record})
+					}
+					for _, testName := range record.FailingTests {
+						testSigs := findSigs(testName)
+						if len(testSigs) == 0 {
+							testSigs = []string{noSig}
+						}
+						for _, sig := range uniqueSortedStrings(testSigs) {
+							getGroup(sig).Tests = append(getGroup(sig).Tests, testName)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	sigReports := []SigReport{}
+	for _, group := range groups {
+		group.Tests = uniqueSortedStrings(group.Tests)
+		sigReports = append(sigReports, *group)
+	}
+	sort.Slice(sigReports, func(i, j int) bool {
+		if (sigReports[i].Sig == noSig) != (sigReports[j].Sig == noSig) {
+			return sigReports[j].Sig == noSig
+		}
+		return sigReports[i].Sig < sigReports[j].Sig
+	})
+	return sigReports
+}
+
+// UnassignedIssuesBySig returns the sig reports of the sigs that own issues without assignee, only these issues are
+// part of the sig reports
+func UnassignedIssuesBySig(report Report) []SigReport {
+	unassigned := []SigReport{}
+	for _, sigReport := range GroupBySig(report) {
+		issues := []ReportDataRecord{}
+		for _, issue := range sigReport.Issues {
+			if len(issue.Assignees) == 0 {
+				issues = append(issues, issue)
+			}
+		}
+		if len(issues) != 0 {
+			unassigned = append(unassigned, SigReport{Sig: sigReport.Sig, Jobs: []SigReportJob{}, Tests: []string{}, Issues: issues})
+		}
+	}
+	return unassigned
+}
+
+// PrintGroupedBySig prints the report grouped by sig to the console
+func PrintGroupedBySig(meta Meta, report Report) {
+	sigReports := GroupBySig(report)
+	if meta.Flags.JSONOut {
+		b, err := json.MarshalIndent(sigReports, "", "  ")
+		if err != nil {
+			log.Fatalf("Could not marshal sig report %v", err)
+		}
+		fmt.Print(string(b))
+		return
+	}
+	for _, sigReport := range sigReports {
+		if sigReport.Sig == noSig {
+			fmt.Print("\n\nNO SIG\n")
+		} else {
+			fmt.Printf("\n\nSIG %s\n", strings.ToUpper(sigReport.Sig))
+		}
+		if len(sigReport.Jobs) != 0 {
+			fmt.Print("\nFAILING, FLAKY & STALE JOBS:\n")
+			for _, job := range sigReport.Jobs {
+				fmt.Printf("%s %s %s\n", job.Dashboard, job.Job.Status, job.Job.Title)
+				if !meta.Flags.ShortOn {
+					fmt.Printf("- %s\n", job.Job.URL)
+				}
+			}
+		}
+		if len(sigReport.Tests) != 0 && !meta.Flags.ShortOn {
+			fmt.Print("\nFAILING TESTS:\n")
+			for _, testName := range sigReport.Tests {
+				fmt.Printf("- %s\n", testName)
+			}
+		}
+		if len(sigReport.Issues) != 0 {
+			fmt.Print("\nOPEN ISSUES:\n")
+			for _, issue := range sigReport.Issues {
+				owner := "unassigned"
+				if len(issue.Assignees) != 0 {
+					owner = strings.Join(issue.Assignees, ", ")
+				}
+				fmt.Printf("%s %s (%s)\n", issueReference(issue), issue.Title, owner)
+				if !meta.Flags.ShortOn {
+					fmt.Printf("- %s\n", issue.URL)
+				}
+			}
+		}
+	}
+	fmt.Println()
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindSigs(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{input: "sig/storage", want: []string{"storage"}},
+		{input: "sig/Cluster-Lifecycle", want: []string{"cluster-lifecycle"}},
+		{input: "Kubernetes e2e suite.[sig-storage] CSI Volumes [sig-node] Pods", want: []string{"storage", "node"}},
+		{input: "kind/flake", want: []string{}},
+	}
+	for _, tt := range tests {
+		if got := findSigs(tt.input); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("findSigs(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestSplitSigInput(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{input: "", want: []string{}},
+		{input: "sig/storage, node", want: []string{"node", "storage"}},
+		{input: "sig-Node,node,, SIG/node", want: []string{"node"}},
+	}
+	for _, tt := range tests {
+		if got := splitSigInput(tt.input); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitSigInput(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestGroupBySig(t *testing.T) {
+	storageTest := "Kubernetes e2e suite.[sig-storage] CSI Volumes should mount"
+	nodeTest := "Kubernetes e2e suite.[sig-node] Pods should be updated"
+	failingJob := getDetails("ci-kubernetes-e2e-gci-gce", testgridValue{OverallStatus: failing, Status: "1 of 10 (10.0%) recent columns passed", Tests: []test{
+		{TestName: storageTest},
+		{TestName: nodeTest},
+		{TestName: "Overall"},
+	}}, testgridTestURL, true)
+	flakyJob := getDetails("ci-kubernetes-e2e-gce-network", testgridValue{OverallStatus: flaky, Status: "8 of 10 (80.0%) recent columns passed", Tests: []test{
+		{TestName: "Kubernetes e2e suite.[sig-network] Services should serve"},
+	}}, testgridTestURL, true)
+	staleJob := ReportDataRecord{ID: testgridReportStale, Title: "ci-kubernetes-build", Status: string(stale)}
+	report := Report{
+		{Name: testgridReport, Data: []ReportDataField{{Title: "Master-Blocking", Records: []ReportDataRecord{
+			{ID: testgridReportSummary, FailingJobs: []string{failingJob.Title}, FlakyJobs: []string{flakyJob.Title}},
+			failingJob,
+			flakyJob,
+			staleJob,
+		}}}},
+		{Name: githubReport, Data: []ReportDataField{{Records: []ReportDataRecord{
+			{ID: 1, Title: "node issue", Sigs: findSigs("sig/node"), Assignees: []string{"alice"}},
+			{ID: 2, Title: "storage and node issue", Sigs: uniqueSortedStrings(append(findSigs("sig/storage"), findSigs("sig/node")...))},
+			{ID: 3, Title: "issue without sig label"},
+		}}}},
+	}
+
+	type group struct {
+		jobs   []string
+		tests  []string
+		issues []int64
+	}
+	want := map[string]group{
+		"network": {jobs: []string{"ci-kubernetes-e2e-gce-network"}, tests: []string{}, issues: []int64{}},
+		"node":    {jobs: []string{"ci-kubernetes-e2e-gci-gce"}, tests: []string{nodeTest}, issues: []int64{1, 2}},
+		"storage": {jobs: []string{"ci-kubernetes-e2e-gci-gce"}, tests: []string{storageTest}, issues: []int64{2}},
+		// the flaky job has no failing tests, failing tests without sig are grouped with the failing job
+		noSig: {jobs: []string{"ci-kubernetes-build"}, tests: []string{"Overall"}, issues: []int64{3}},
+	}
+	wantOrder := []string{"network", "node", "storage", noSig}
+
+	sigReports := GroupBySig(report)
+	gotOrder := []string{}
+	for _, sigReport := range sigReports {
+		gotOrder = append(gotOrder, sigReport.Sig)
+		got := group{jobs: []string{}, tests: sigReport.Tests, issues: []int64{}}
+		for _, job := range sigReport.Jobs {
+			if job.Dashboard != "Master-Blocking" {
+				t.Errorf("sig %s: job %s is on dashboard %q", sigReport.Sig, job.Job.Title, job.Dashboard)
+			}
+			got.jobs = append(got.jobs, job.Job.Title)
+		}
+		for _, issue := range sigReport.Issues {
+			got.issues = append(got.issues, issue.ID)
+		}
+		if !reflect.DeepEqual(got, want[sigReport.Sig]) {
+			t.Errorf("sig %s = %+v, want %+v", sigReport.Sig, got, want[sigReport.Sig])
+		}
+	}
+	if !reflect.DeepEqual(gotOrder, wantOrder) {
+		t.Errorf("GroupBySig() sigs = %q, want %q", gotOrder, wantOrder)
+	}
+
+	// sigs without unassigned issues are left out
+	gotUnassigned := map[string][]int64{}
+	for _, sigReport := range UnassignedIssuesBySig(report) {
+		for _, issue := range sigReport.Issues {
+			gotUnassigned[sigReport.Sig] = append(gotUnassigned[sigReport.Sig], issue.ID)
+		}
+	}
+	if want := map[string][]int64{"node": {2}, "storage": {2}, noSig: {3}}; !reflect.DeepEqual(gotUnassigned, want) {
+		t.Errorf("UnassignedIssuesBySig() = %v, want %v", gotUnassigned, want)
+	}
+}
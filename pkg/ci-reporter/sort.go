@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"sort"
+	"time"
+)
+
+// Sort options that can be set via -sort
+const (
+	sortBySeverity = "severity"
+	sortByAge      = "age"
+	sortBySig      = "sig"
+	sortByName     = "name"
+)
+
+var sortOptions = []string{sortBySeverity, sortByAge, sortBySig, sortByName}
+
+// recordLess compares two records, every comparison falls back to the next one to get a total order
+type recordLess func(a, b ReportDataRecord) (less bool, decided bool)
+
+// sortRecords sorts report records in place, the order is stable across runs
+// - severity: severity (highest first), age, sig, name
+// - age: age (oldest first), severity, sig, name
+// - sig: sig, severity, name
+// - name: name / number
+func sortRecords(records []ReportDataRecord, sortBy string) {
+	var order []recordLess
+	switch sortBy {
+	case sortByAge:
+		order = []recordLess{lessByAge, lessBySeverity, lessBySig, lessByName}
+	case sortBySig:
+		order = []recordLess{lessBySig, lessBySeverity, lessByName}
+	case sortByName:
+		order = []recordLess{lessByName}
+	default:
+		order = []recordLess{lessBySeverity, lessByAge, lessBySig, lessByName}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		for _, less := range order {
+			if l, decided := less(records[i], records[j]); decided {
+				return l
+			}
+		}
+		return false
+	})
+}
+
+func lessBySeverity(a, b ReportDataRecord) (bool, bool) {
+	return a.Severity > b.Severity, a.Severity != b.Severity
+}
+
+// records without a timestamp are sorted after the ones with a timestamp
+func lessByAge(a, b ReportDataRecord) (bool, bool) {
+	ta, tb := recordSince(a), recordSince(b)
+	if (ta == nil) != (tb == nil) {
+		return ta != nil, true
+	}
+	if ta == nil || ta.Equal(*tb) {
+		return false, false
+	}
+	return ta.Before(*tb), true
+}
+
+func lessBySig(a, b ReportDataRecord) (bool, bool) {
+	return a.Sig < b.Sig, a.Sig != b.Sig
+}
+
+// github records are identified by their number and repository, testgrid records by the job name
+func lessByName(a, b ReportDataRecord) (bool, bool) {
+	if a.ID != b.ID {
+		return a.ID < b.ID, true
+	}
+	if a.Repo != b.Repo {
+		return a.Repo < b.Repo, true
+	}
+	if a.Title != b.Title {
+		return a.Title < b.Title, true
+	}
+	return a.URL < b.URL, a.URL != b.URL
+}
+
+// recordSince returns the point in time the age of a record is measured from
+func recordSince(r ReportDataRecord) *time.Time {
+	if r.FailingSince != nil {
+		return r.FailingSince
+	}
+	return r.CreatedAt
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// sortFixture github issues with equal severities, ages and sigs, so that every sort order needs its fallbacks
+func sortFixture() []ReportDataRecord {
+	day := func(d int) *time.Time {
+		t := time.Date(2021, 10, d, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+	return []ReportDataRecord{
+		{ID: 105965, Title: "volume metrics tests failure", Sig: "sig/storage", Sigs: []string{"storage"}, Severity: HighSeverity, CreatedAt: day(20), Repo: defaultGithubRepo, MatchedBy: []string{githubLabelFailingTest}},
+		{ID: 106139, Title: "Failure test: Volume metrics Ephemeral", Sig: "sig/storage", Sigs: []string{"storage"}, Severity: HighSeverity, CreatedAt: day(20), Repo: defaultGithubRepo, MatchedBy: []string{githubLabelFailingTest}},
+		{ID: 105242, Title: "TestApfWatchHandlePanic flakes", Sig: "sig/api-machinery", Sigs: []string{"api-machinery"}, Severity: MediumSeverity, CreatedAt: day(5), Repo: defaultGithubRepo, MatchedBy: []string{githubLabelFlake}},
+		{ID: 2312, Title: "kind cluster fails to start", Sig: "sig/testing", Sigs: []string{"testing"}, Severity: MediumSeverity, CreatedAt: day(5), Repo: "kubernetes-sigs/kind", MatchedBy: []string{githubLabelFailingTest, githubLabelFlake}},
+		{ID: 2312, Title: "e2e image push flakes", Sig: "sig/testing", Sigs: []string{"testing"}, Severity: MediumSeverity, CreatedAt: day(5), Repo: "kubernetes/test-infra", MatchedBy: []string{githubLabelFlake}},
+		{ID: 104000, Title: "no creation time", Sig: "sig/node", Sigs: []string{"node"}, Severity: LightSeverity, Repo: defaultGithubRepo, MatchedBy: []string{githubLabelFlake}},
+		{ID: 104001, Title: "no creation time", Sig: "sig/node", Sigs: []string{"node"}, Severity: LightSeverity, Repo: defaultGithubRepo, MatchedBy: []string{githubLabelFlake}},
+	}
+}
+
+// shuffledSortFixtures returns the fixture in a number of random orders (with a fixed seed to be reproducible)
+func shuffledSortFixtures(n int) [][]ReportDataRecord {
+	r := rand.New(rand.NewSource(1))
+	shuffled := [][]ReportDataRecord{sortFixture()}
+	for i := 1; i < n; i++ {
+		records := sortFixture()
+		r.Shuffle(len(records), func(i, j int) { records[i], records[j] = records[j], records[i] })
+		shuffled = append(shuffled, records)
+	}
+	return shuffled
+}
+
+func TestSortRecords(t *testing.T) {
+	tests := []struct {
+		sortBy string
+		want   []string
+	}{
+		{sortBy: sortBySeverity, want: []string{"#105965", "#106139", "#105242", "kubernetes-sigs/kind#2312", "kubernetes/test-infra#2312", "#104000", "#104001"}},
+		{sortBy: sortByAge, want: []string{"#105242", "kubernetes-sigs/kind#2312", "kubernetes/test-infra#2312", "#105965", "#106139", "#104000", "#104001"}},
+		{sortBy: sortBySig, want: []string{"#105242", "#104000", "#104001", "#105965", "#106139", "kubernetes-sigs/kind#2312", "kubernetes/test-infra#2312"}},
+		{sortBy: sortByName, want: []string{"kubernetes-sigs/kind#2312", "kubernetes/test-infra#2312", "#104000", "#104001", "#105242", "#105965", "#106139"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sortBy, func(t *testing.T) {
+			for _, records := range shuffledSortFixtures(20) {
+				sortRecords(records, tt.sortBy)
+				got := []string{}
+				for _, record := range records {
+					got = append(got, issueReference(record))
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("sortRecords(%s) = %q, want %q", tt.sortBy, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestPrintSortedRecords(t *testing.T) {
+	meta := Meta{Flags: metaFlags{ShortOn: true}}
+	want := `
+
+FAILING TESTS:
+#105965 volume metrics tests failure sig/storage
+#106139 Failure test: Volume metrics Ephemeral sig/storage
+kubernetes-sigs/kind#2312 kind cluster fails to start sig/testing (failing test and flake)
+
+FLAKES:
+#105242 TestApfWatchHandlePanic flakes sig/api-machinery
+kubernetes/test-infra#2312 e2e image push flakes sig/testing
+#104000 no creation time sig/node
+#104001 no creation time sig/node
+
+UNASSIGNED ISSUES BY SIG:
+api-machinery: 1 (#105242)
+node: 2 (#104000, #104001)
+storage: 2 (#105965, #106139)
+testing: 2 (kubernetes-sigs/kind#2312, kubernetes/test-infra#2312)
+
+`
+	for i, records := range shuffledSortFixtures(20) {
+		sortRecords(records, sortBySeverity)
+		reportData := ReportData{Name: githubReport, Data: []ReportDataField{{Records: records}}}
+		got := captureStdout(t, func() { GithubReport{}.Print(meta, reportData) })
+		if got != want {
+			t.Fatalf("output of shuffle %d:\n%s\nwant:\n%s", i, got, want)
+		}
+	}
+}
+
+// captureStdout returns what print writes to stdout
+func captureStdout(t *testing.T, print func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("could not create a pipe: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	done := make(chan []byte)
+	go func() {
+		b, _ := ioutil.ReadAll(r)
+		done <- b
+	}()
+	print()
+	w.Close()
+	return string(<-done)
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	htmltemplate "html/template"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ReportTemplateData data a template set via -template is rendered with
+type ReportTemplateData struct {
+	GeneratedAt time.Time
+	Report      Report
+}
+
+// executableTemplate is implemented by text/template and html/template
+type executableTemplate interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+// RenderReport renders the report with the template set via -template
+func RenderReport(w io.Writer, meta Meta, report Report, generatedAt time.Time) error {
+	tmpl, err := parseTemplateFile(meta.Flags.Template)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, ReportTemplateData{GeneratedAt: generatedAt, Report: report})
+}
+
+// parseTemplateFile parses a template file, files with '.html' in their name (like 'email.html.tmpl') are parsed
+// with html/template so values are escaped automatically, all others with text/template
+func parseTemplateFile(path string) (executableTemplate, error) {
+	text, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	name := filepath.Base(path)
+	if strings.Contains(strings.ToLower(name), ".html") {
+		return htmltemplate.New(name).Funcs(htmltemplate.FuncMap(templateFuncs)).Parse(string(text))
+	}
+	return template.New(name).Funcs(templateFuncs).Parse(string(text))
+}
+
+// templateFuncs helper functions that can be used in templates (in addition to the text/template builtins like
+// 'html' or 'printf')
+var templateFuncs = template.FuncMap{
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	// since formats the duration from t (time.Time or *time.Time) until now like '6d 3h'
+	"since": templateSince,
+	// count returns the number of jobs of a dashboard summary by status like 'failing'
+	"count": func(counts map[string]int, status string) int {
+		return counts[strings.ToLower(status)]
+	},
+	"severityEmoji": severityEmoji,
+	// groupBySig splits the report into sig reports like -group-by sig
+	"groupBySig": GroupBySig,
+	// unassignedBySig like groupBySig with the issues without assignee only
+	"unassignedBySig": UnassignedIssuesBySig,
+	// issueSections splits issue records into '.FailingTests' and '.Flakes' (issues found by both are failing tests)
+	"issueSections":  SplitIssues,
+	"escapeMarkdown": escapeMarkdown,
+	// issueReference references a github issue like '#105965', issues of other repositories than
+	// kubernetes/kubernetes with their repository like 'kubernetes-sigs/kind#2312'
+	"issueReference": issueReference,
+	// stripANSI removes the terminal color codes notes can contain
+	"stripANSI": stripANSI,
+	// isSummary checks if a record is the summary of a testgrid dashboard
+	"isSummary": func(reportName string, record ReportDataRecord) bool {
+		return reportName == testgridReport && record.ID == testgridReportSummary
+	},
+}
+
+func templateSince(t interface{}) string {
+	switch v := t.(type) {
+	case time.Time:
+		return humanDuration(time.Since(v))
+	case *time.Time:
+		if v != nil {
+			return humanDuration(time.Since(*v))
+		}
+	}
+	return ""
+}
+
+// severityEmoji returns a colored circle for a severity (red high, orange medium, yellow light)
+func severityEmoji(severity Severity) string {
+	switch severity {
+	case HighSeverity:
+		return statusFailingEmoji
+	case MediumSeverity:
+		return severityMediumEmoji
+	case LightSeverity:
+		return severityLightEmoji
+	}
+	return ""
+}
+
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "|", `\|`, "#", `\#`)
+
+// escapeMarkdown escapes the characters that would be interpreted as markdown
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
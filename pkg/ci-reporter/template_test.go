@@ -0,0 +1,197 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+)
+
+// templateGeneratedAt fixed time templates are rendered at
+var templateGeneratedAt = time.Date(2021, time.November, 1, 9, 0, 0, 0, time.UTC)
+
+// writeTemplateFile writes a user template to a temporary directory and returns its path
+func writeTemplateFile(t *testing.T, name string, text string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRenderReport(t *testing.T) {
+	report := Report{{Name: githubReport, Data: []ReportDataField{{Records: []ReportDataRecord{
+		{ID: 2312, Repo: "kubernetes-sigs/kind", Title: "<b>kind</b> flakes"},
+	}}}}}
+	tests := []struct {
+		name string
+		file string
+		text string
+		want string
+	}{
+		{
+			name: "text template",
+			file: "report.tmpl",
+			text: `{{ .GeneratedAt.Format "2006-01-02" }}{{ range .Report }} {{ upper .Name }}{{ range .Data }}{{ range .Records }} {{ issueReference . }} {{ .Title }}{{ end }}{{ end }}{{ end }}`,
+			want: "2021-11-01 GITHUB kubernetes-sigs/kind#2312 <b>kind</b> flakes",
+		},
+		{
+			name: "html template escapes values",
+			file: "report.html.tmpl",
+			text: `<p>{{ range .Report }}{{ range .Data }}{{ range .Records }}{{ .Title }}{{ end }}{{ end }}{{ end }}</p>`,
+			want: "<p>&lt;b&gt;kind&lt;/b&gt; flakes</p>",
+		},
+		{
+			name: "html is detected case insensitive",
+			file: "Report.HTML",
+			text: `{{ range .Report }}{{ range .Data }}{{ range .Records }}{{ .Title }}{{ end }}{{ end }}{{ end }}`,
+			want: "&lt;b&gt;kind&lt;/b&gt; flakes",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := Meta{Flags: metaFlags{Template: writeTemplateFile(t, tt.file, tt.text)}}
+			var b bytes.Buffer
+			if err := RenderReport(&b, meta, report, templateGeneratedAt); err != nil {
+				t.Fatalf("RenderReport() error = %v", err)
+			}
+			if got := b.String(); got != tt.want {
+				t.Errorf("RenderReport() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderReportExampleTemplates(t *testing.T) {
+	failingSince := time.Now().Add(-50 * time.Hour)
+	report := loadReportFixture(t, "current.json")
+	report[0].Data[0].Records[1].FailingSince = &failingSince
+	files, err := filepath.Glob(filepath.Join("..", "..", "examples", "templates", "*.tmpl"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no example templates found: %v", err)
+	}
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			var b bytes.Buffer
+			if err := RenderReport(&b, Meta{Flags: metaFlags{Template: file}}, report, templateGeneratedAt); err != nil {
+				t.Fatalf("RenderReport() error = %v", err)
+			}
+			for _, want := range []string{"2021-11-01", "kind-master-parallel", "failing for 2d 2h"} {
+				if !strings.Contains(b.String(), want) {
+					t.Errorf("RenderReport() does not contain %q:\n%s", want, b.String())
+				}
+			}
+		})
+	}
+}
+
+func TestRenderReportBrokenTemplate(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		text string
+	}{
+		{name: "unclosed action", file: "report.tmpl", text: `{{ .Report`},
+		{name: "unknown function", file: "report.tmpl", text: `{{ unknown .Report }}`},
+		{name: "unknown field", file: "report.tmpl", text: `{{ .Unknown }}`},
+		{name: "wrong argument", file: "report.tmpl", text: `{{ count .Report "failing" }}`},
+		{name: "html unclosed action", file: "report.html", text: `<p>{{ .Report</p>`},
+		{name: "html unknown field", file: "report.html", text: `<p>{{ .Unknown }}</p>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := Meta{Flags: metaFlags{Template: writeTemplateFile(t, tt.file, tt.text)}}
+			if err := RenderReport(ioutil.Discard, meta, Report{}, templateGeneratedAt); err == nil {
+				t.Error("RenderReport() returned no error")
+			}
+			var err error
+			captureStdout(t, func() { err = PrintReport(meta, nil, Report{}) })
+			if err == nil || !strings.Contains(err.Error(), meta.Flags.Template) {
+				t.Errorf("PrintReport() error = %v, want an error naming the template", err)
+			}
+		})
+	}
+
+	meta := Meta{Flags: metaFlags{Template: filepath.Join(t.TempDir(), "missing.tmpl")}}
+	if err := RenderReport(ioutil.Discard, meta, Report{}, templateGeneratedAt); err == nil {
+		t.Error("RenderReport() with a missing template returned no error")
+	}
+}
+
+func TestWeeklyTemplateBroken(t *testing.T) {
+	meta := Meta{Command: WeeklyCommand, Flags: metaFlags{
+		Template:       writeTemplateFile(t, "weekly.tmpl", `{{ range .Dashboards }}`),
+		Snapshot:       filepath.Join(t.TempDir(), "snapshot.json"),
+		UpdateSnapshot: true,
+	}}
+	if err := WriteWeeklySummary(ioutil.Discard, meta, Report{}, templateGeneratedAt); err == nil {
+		t.Error("WriteWeeklySummary() with a broken template returned no error")
+	}
+	// the snapshot is only stored if the summary could be rendered
+	if snapshot, err := loadWeeklySnapshot(meta.Flags.Snapshot); snapshot != nil || err != nil {
+		t.Errorf("loadWeeklySnapshot() = %v, %v, want no snapshot", snapshot, err)
+	}
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	failingSince := time.Now().Add(-(3*24 + 5) * time.Hour)
+	tests := []struct {
+		name string
+		text string
+		data interface{}
+		want string
+	}{
+		{name: "join", text: `{{ join . ", " }}`, data: []string{"node", "storage"}, want: "node, storage"},
+		{name: "upper", text: `{{ upper . }}`, data: "github", want: "GITHUB"},
+		{name: "lower", text: `{{ lower . }}`, data: "FAILING", want: "failing"},
+		{name: "since time", text: `{{ since . }}`, data: failingSince, want: "3d 5h"},
+		{name: "since pointer", text: `{{ since . }}`, data: &failingSince, want: "3d 5h"},
+		{name: "since nil", text: `{{ since . }}`, data: (*time.Time)(nil), want: ""},
+		{name: "count", text: `{{ count . "FAILING" }} {{ count . "flaky" }} {{ count . "stale" }}`, data: map[string]int{"failing": 2, "flaky": 1}, want: "2 1 0"},
+		{name: "severityEmoji", text: `{{ severityEmoji . }}`, data: HighSeverity, want: statusFailingEmoji},
+		{name: "severityEmoji none", text: `{{ severityEmoji . }}`, data: Severity(0), want: ""},
+		{name: "escapeMarkdown", text: `{{ escapeMarkdown . }}`, data: "[sig-node] *Serial* #1", want: `\[sig-node\] \*Serial\* \#1`},
+		{name: "issueReference", text: `{{ issueReference . }}`, data: ReportDataRecord{ID: 2312, Repo: "kubernetes-sigs/kind"}, want: "kubernetes-sigs/kind#2312"},
+		{name: "issueReference default repository", text: `{{ issueReference . }}`, data: ReportDataRecord{ID: 105965, Repo: defaultGithubRepo}, want: "#105965"},
+		{name: "stripANSI", text: `{{ stripANSI . }}`, data: "\x1b[31mfailing\x1b[0m", want: "failing"},
+		{name: "isSummary", text: `{{ isSummary "testgrid" . }} {{ isSummary "github" . }}`, data: ReportDataRecord{ID: testgridReportSummary}, want: "true false"},
+		{name: "groupBySig", text: `{{ range groupBySig . }}{{ .Sig }}:{{ len .Issues }} {{ end }}`, data: Report{{Name: githubReport, Data: []ReportDataField{{Records: []ReportDataRecord{{ID: 1, Sigs: []string{"node"}}, {ID: 2}}}}}}, want: "node:1 none:1 "},
+		{name: "unassignedBySig", text: `{{ range unassignedBySig . }}{{ .Sig }}:{{ len .Issues }} {{ end }}`, data: Report{{Name: githubReport, Data: []ReportDataField{{Records: []ReportDataRecord{{ID: 1, Sigs: []string{"node"}, Assignees: []string{"a"}}, {ID: 2}}}}}}, want: "none:1 "},
+		{name: "issueSections", text: `{{ with issueSections . }}{{ len .FailingTests }} {{ len .Flakes }}{{ end }}`, data: []ReportDataRecord{{ID: 1, MatchedBy: []string{githubLabelFailingTest, githubLabelFlake}}, {ID: 2, MatchedBy: []string{githubLabelFlake}}}, want: "1 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := template.New(tt.name).Funcs(templateFuncs).Parse(tt.text)
+			if err != nil {
+				t.Fatalf("could not parse %q: %v", tt.text, err)
+			}
+			var b bytes.Buffer
+			if err := tmpl.Execute(&b, tt.data); err != nil {
+				t.Fatalf("could not execute %q: %v", tt.text, err)
+			}
+			if got := b.String(); got != tt.want {
+				t.Errorf("%s = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -17,49 +17,66 @@ limitations under the License.
 package cireporter
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"net/http"
-	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
+func init() {
+	RegisterReporter(testgridReport, "failing and flaky jobs of the sig-release testgrid dashboards", func() CIReport { return &TestgridReport{} })
+}
+
 // TestgridReport used to implement RequestData & Print for testgrid report data
 type TestgridReport struct {
 	ReportData ReportData
 }
 
 // RequestData this function is used to accumulate a summary of testgrid
-func (r *TestgridReport) RequestData(meta Meta, wg *sync.WaitGroup) ReportData {
+func (r *TestgridReport) RequestData(ctx context.Context, meta Meta, wg *sync.WaitGroup) ReportData {
 	// The report checks master-blocking and master-informing
 	requiredJobs := []testgridJob{
-		{OutputName: "Master-Blocking", URLName: string(sigReleaseMasterBlocking), Emoji: masterBlockingEmoji},
-		{OutputName: "Master-Informing", URLName: string(sigReleaseMasterInforming), Emoji: masterInformingEmoji},
+		{OutputName: "Master-Blocking", URLName: string(sigReleaseMasterBlocking), Emoji: masterBlockingEmoji, StaleAfter: blockingStaleAfter},
+		{OutputName: "Master-Informing", URLName: string(sigReleaseMasterInforming), Emoji: masterInformingEmoji, StaleAfter: informingStaleAfter},
 	}
 
 	// If a release version got specified add additional jobs to report
 	if len(meta.Flags.ReleaseVersion) > 0 {
 		for _, r := range meta.Flags.ReleaseVersion {
-			requiredJobs = append(requiredJobs, testgridJob{OutputName: fmt.Sprintf("%s-blocking", r), URLName: fmt.Sprintf("sig-release-%s-blocking", r), Emoji: masterBlockingEmoji})
-			requiredJobs = append(requiredJobs, testgridJob{OutputName: fmt.Sprintf("%s-informing", r), URLName: fmt.Sprintf("sig-release-%s-informing", r), Emoji: masterInformingEmoji})
+			requiredJobs = append(requiredJobs, testgridJob{OutputName: fmt.Sprintf("%s-blocking", r), URLName: fmt.Sprintf("sig-release-%s-blocking", r), Emoji: masterBlockingEmoji, StaleAfter: blockingStaleAfter})
+			requiredJobs = append(requiredJobs, testgridJob{OutputName: fmt.Sprintf("%s-informing", r), URLName: fmt.Sprintf("sig-release-%s-informing", r), Emoji: masterInformingEmoji, StaleAfter: informingStaleAfter})
 		}
 	}
 
-	return meta.DataPostProcessing(r, testgridReport, assembleTestgridRequests(meta, requiredJobs), wg)
+	// The expected cadence can be overwritten for all dashboards
+	if meta.Flags.StaleAfter > 0 {
+		for i := range requiredJobs {
+			requiredJobs[i].StaleAfter = meta.Flags.StaleAfter
+		}
+	}
+
+	return meta.DataPostProcessing(r, testgridReport, assembleTestgridRequests(ctx, meta, requiredJobs), wg)
 }
 
 // Print extends TestgridReport and prints report data to the console
 func (r *TestgridReport) Print(meta Meta, reportData ReportData) {
 	for _, reportField := range reportData.Data {
+		staleHeaderPrinted := false
 		headerLine := fmt.Sprintf("\n\n%s Tests in %s", reportField.Emoji, reportField.Title)
 		if meta.Flags.EmojisOff {
 			headerLine = fmt.Sprintf("\n\nTests in %s", reportField.Title)
 		}
+		if reportField.Error != "" {
+			fmt.Println(headerLine)
+			fmt.Printf("- could not be requested: %s\n", reportField.Error)
+			continue
+		}
 		for _, stat := range reportField.Records {
 			if stat.ID == testgridReportSummary {
 				fmt.Println(headerLine)
@@ -71,20 +88,31 @@ func (r *TestgridReport) Print(meta Meta, reportData ReportData) {
 					fmt.Print("\nFAILING & FLAKY JOBS:\n")
 				}
 			} else if stat.ID == testgridReportDetails {
-				if meta.Flags.EmojisOff {
-					fmt.Printf("%s severity:%d, %s\n", stat.Status, stat.Severity, stat.Title)
-				} else {
-					fmt.Printf("%s %s %s\n", stat.Status, stat.Highlight, stat.Title)
-				}
-				fmt.Printf("- %s\n", stat.URL)
-				for _, note := range stat.Notes {
-					fmt.Printf("- %s\n", note)
+				printTestgridJob(meta, stat)
+			} else if stat.ID == testgridReportStale {
+				if !staleHeaderPrinted {
+					fmt.Print("\nSTALE / NOT RUNNING JOBS:\n")
+					staleHeaderPrinted = true
 				}
+				printTestgridJob(meta, stat)
 			}
 		}
 	}
 }
 
+// This function is used to print a single failing, flaky or stale job
+func printTestgridJob(meta Meta, stat ReportDataRecord) {
+	if meta.Flags.EmojisOff {
+		fmt.Printf("%s severity:%d, %s\n", stat.Status, stat.Severity, stat.Title)
+	} else {
+		fmt.Printf("%s %s %s\n", stat.Status, stat.Highlight, stat.Title)
+	}
+	fmt.Printf("- %s\n", stat.URL)
+	for _, note := range stat.Notes {
+		fmt.Printf("- %s\n", note)
+	}
+}
+
 // PutData extends TestgridReport and stores the data at runtime to the struct val ReportData
 func (r *TestgridReport) PutData(reportData ReportData) {
 	r.ReportData = reportData
@@ -95,58 +123,94 @@ func (r TestgridReport) GetData() ReportData {
 	return r.ReportData
 }
 
-func assembleTestgridRequests(meta Meta, requiredJobs []testgridJob) chan ReportDataField {
+func assembleTestgridRequests(ctx context.Context, meta Meta, requiredJobs []testgridJob) chan ReportDataField {
 	c := make(chan ReportDataField)
 	go func() {
 		defer close(c)
+		// Dashboards are requested concurrently but reported in the order they are configured
+		dashboards := make([]ReportDataField, len(requiredJobs))
 		wg := sync.WaitGroup{}
-		for _, j := range requiredJobs {
+		for i, j := range requiredJobs {
 			wg.Add(1)
-			go func(job testgridJob) {
+			go func(i int, job testgridJob) {
 				jobBaseURL := fmt.Sprintf("https://testgrid.k8s.io/%s", job.URLName)
-				jobsData, err := reqTestgridSiteData(job, jobBaseURL)
+				jobsData, err := reqTestgridSiteData(ctx, meta.Fetcher, jobBaseURL)
 				if err != nil {
-					log.Fatalf("error %v", err)
-				}
-				records := []ReportDataRecord{getSummary(jobsData)}
-
-				if !meta.Flags.ShortOn {
-					for jobName, jobData := range jobsData {
-						if jobData.OverallStatus != passing {
-							records = append(records, getDetails(jobName, jobData, jobBaseURL, meta.Flags.EmojisOff))
-						}
+					// The dashboard is reported without records to not lose the data of the other dashboards
+					dashboards[i] = ReportDataField{
+						Emoji:   job.Emoji,
+						Title:   job.OutputName,
+						Records: []ReportDataRecord{},
+						Error:   err.Error(),
 					}
+					wg.Done()
+					return
 				}
-
-				reportData := ReportDataField{
+				records := getDashboardRecords(meta, job, jobsData, jobBaseURL, time.Now())
+				dashboards[i] = ReportDataField{
 					Emoji:   job.Emoji,
 					Title:   job.OutputName,
 					Records: records,
 				}
-				c <- reportData
 				wg.Done()
-			}(j)
+			}(i, j)
 		}
 		wg.Wait()
+		for _, dashboard := range dashboards {
+			c <- dashboard
+		}
 	}()
 	return c
 }
 
+// This function is used to assemble the summary, failing & flaky and stale jobs of a dashboard
+func getDashboardRecords(meta Meta, job testgridJob, jobsData TestgridData, jobBaseURL string, now time.Time) []ReportDataRecord {
+	summary := getSummary(jobsData)
+	staleRecords := getStaleJobs(jobsData, jobBaseURL, job.StaleAfter, meta.Flags.StaleSeverity, now)
+	// the summary keeps the highest severity of all jobs (stale ones included), the details are left out
+	// with -short and filtered by -sig
+	if len(staleRecords) != 0 {
+		summary.Notes = append(summary.Notes, fmt.Sprintf("%d jobs not run in %s", len(staleRecords), humanDuration(job.StaleAfter)))
+		if meta.Flags.StaleSeverity > summary.Severity {
+			summary.Severity = meta.Flags.StaleSeverity
+		}
+	}
+	details := []ReportDataRecord{}
+	for jobName, jobData := range jobsData {
+		if jobData.OverallStatus != passing {
+			detail := getDetails(jobName, jobData, jobBaseURL, meta.Flags.EmojisOff)
+			if detail.Severity > summary.Severity {
+				summary.Severity = detail.Severity
+			}
+			details = append(details, detail)
+		}
+	}
+	records := []ReportDataRecord{summary}
+
+	if !meta.Flags.ShortOn {
+		details = filterRecordsBySig(meta, details)
+		staleRecords = filterRecordsBySig(meta, staleRecords)
+		sortRecords(details, meta.Flags.SortBy)
+		sortRecords(staleRecords, meta.Flags.SortBy)
+		records = append(records, details...)
+		records = append(records, staleRecords...)
+	}
+	return records
+}
+
 // This function is used to request job summary data from a testgrid subpage
-func reqTestgridSiteData(job testgridJob, jobBaseURL string) (TestgridData, error) {
+func reqTestgridSiteData(ctx context.Context, fetcher *Fetcher, jobBaseURL string) (TestgridData, error) {
 	// This url points to testgrid/summary which returns a JSON document
 	url := fmt.Sprintf("%s/summary", jobBaseURL)
-	resp, err := http.Get(url)
+	resp, err := fetcher.Get(ctx, url, nil)
 	if err != nil {
 		return nil, err
 	}
-	// Parse body form http request
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", url, resp.Status)
 	}
 	// Unmarshal JSON from body into TestgridJobsOverview struct
-	jobs, err := UnmarshalTestgrid(body)
+	jobs, err := UnmarshalTestgrid(resp.Body)
 	if err != nil {
 		return nil, err
 	}
@@ -157,13 +221,16 @@ func reqTestgridSiteData(job testgridJob, jobBaseURL string) (TestgridData, erro
 func getSummary(jobs map[string]testgridValue) ReportDataRecord {
 	result := ReportDataRecord{ID: testgridReportSummary}
 	statuses := map[overallStatus]int{total: len(jobs), passing: 0, failing: 0, flaky: 0, stale: 0}
-	for _, v := range jobs {
+	for name, v := range jobs {
 		if v.OverallStatus == passing {
 			statuses[passing]++
+			result.PassingJobs = append(result.PassingJobs, name)
 		} else if v.OverallStatus == failing {
 			statuses[failing]++
+			result.FailingJobs = append(result.FailingJobs, name)
 		} else if v.OverallStatus == flaky {
 			statuses[flaky]++
+			result.FlakyJobs = append(result.FlakyJobs, name)
 		} else {
 			statuses[stale]++
 		}
@@ -175,6 +242,13 @@ func getSummary(jobs map[string]testgridValue) ReportDataRecord {
 	if statuses[stale] != 0 {
 		result.Notes = append(result.Notes, fmt.Sprintf("%d jobs %s", statuses[stale], strings.ToLower(string(stale))))
 	}
+	sort.Strings(result.PassingJobs)
+	sort.Strings(result.FailingJobs)
+	sort.Strings(result.FlakyJobs)
+	result.Counts = map[string]int{}
+	for status, count := range statuses {
+		result.Counts[strings.ToLower(string(status))] = count
+	}
 	return result
 }
 
@@ -185,21 +259,30 @@ func getDetails(jobName string, jobData testgridValue, jobBaseURL string, emojis
 	result.Title = jobName
 	result.URL = fmt.Sprintf("%s#%s", jobBaseURL, jobName)
 
+	// Filter sigs of failing and flaky jobs, -sig and -group-by sig rely on them
+	result.Sigs = getTestSigs(jobData.Tests)
+	result.Sig = formatSigs(result.Sigs)
+	result.Notes = append(result.Notes, fmt.Sprintf("Sig's involved %s", result.Sig))
+
 	// If the status is failing give information about failing tests
 	if jobData.OverallStatus == failing {
-		// Filter sigs
-		sigRegex := regexp.MustCompile(`sig-[a-zA-Z]+`)
-		sigsInvolved := map[string]int{}
-		for _, test := range jobData.Tests {
-			sigs := sigRegex.FindAllString(test.TestName, -1)
-			for _, sig := range sigs {
-				sigsInvolved[sig] = sigsInvolved[sig] + 1
-			}
+		for _, t := range jobData.Tests {
+			result.FailingTests = append(result.FailingTests, t.TestName)
 		}
-		sigs := reflect.ValueOf(sigsInvolved).MapKeys()
 
-		result.Notes = append(result.Notes, fmt.Sprintf("Sig's involved %v", sigs))
 		result.Notes = append(result.Notes, fmt.Sprintf("Currently %d test are failing", len(jobData.Tests)))
+
+		// Find out since when the job is failing and how many runs failed in a row
+		result.LastGreen = jobData.LatestGreen
+		result.FailingSince, result.FailureStreak = getFailureStreak(jobData.Tests)
+		if result.FailingSince != nil {
+			result.Notes = append(result.Notes, fmt.Sprintf("Failing for %s (since %s), %d consecutive failing runs", humanDuration(time.Since(*result.FailingSince)), result.FailingSince.UTC().Format("2006-01-02 15:04 MST"), result.FailureStreak))
+		}
+		if jobData.LatestGreen != "" {
+			result.Notes = append(result.Notes, fmt.Sprintf("Last green run %s", jobData.LatestGreen))
+		} else {
+			result.Notes = append(result.Notes, "No recent green run")
+		}
 	}
 
 	const (
@@ -221,6 +304,8 @@ func getDetails(jobName string, jobData testgridValue, jobBaseURL string, emojis
 	if err != nil {
 		fmt.Println(err)
 	}
+	result.RecentPasses = int(testgridRegexRecentPassesFloat)
+	result.RecentRuns = int(testgridRegexRecentRunsFloat)
 
 	highlightEmoji := ""
 	if jobData.OverallStatus == failing {
@@ -250,6 +335,66 @@ func getDetails(jobName string, jobData testgridValue, jobBaseURL string, emojis
 	return result
 }
 
+// This function is used to get the start of the current failure streak of a job (the earliest first failure of
+// its failing tests) and the number of consecutive failing runs (the longest streak of its failing tests)
+func getFailureStreak(tests []test) (*time.Time, int) {
+	var failingSince *time.Time
+	streak := 0
+	for _, t := range tests {
+		if t.FailCount > int64(streak) {
+			streak = int(t.FailCount)
+		}
+		if t.FailTimestamp == 0 {
+			continue
+		}
+		failedAt := testgridTimestamp(t.FailTimestamp)
+		if failingSince == nil || failedAt.Before(*failingSince) {
+			failingSince = &failedAt
+		}
+	}
+	return failingSince, streak
+}
+
+// This function is used to find jobs that have not been run within the expected cadence of the dashboard
+func getStaleJobs(jobs map[string]testgridValue, jobBaseURL string, staleAfter time.Duration, severity Severity, now time.Time) []ReportDataRecord {
+	records := []ReportDataRecord{}
+	for jobName, jobData := range jobs {
+		// jobs that never ran have no timestamp, testgrid already reports them as stale
+		if jobData.LastRunTimestamp == 0 {
+			continue
+		}
+		lastRun := testgridTimestamp(jobData.LastRunTimestamp)
+		notRunFor := now.Sub(lastRun)
+		if notRunFor <= staleAfter {
+			continue
+		}
+		sigs := getTestSigs(jobData.Tests)
+		records = append(records, ReportDataRecord{
+			ID:        testgridReportStale,
+			URL:       fmt.Sprintf("%s#%s", jobBaseURL, jobName),
+			Title:     jobName,
+			Sig:       formatSigs(sigs),
+			Sigs:      sigs,
+			Status:    string(stale),
+			Severity:  severity,
+			Highlight: strings.Repeat(statusOldEmoji, int(severity)),
+			Notes: []string{
+				fmt.Sprintf("Last run %s (%s ago, expected every %s)", lastRun.UTC().Format("2006-01-02 15:04 MST"), humanDuration(notRunFor), humanDuration(staleAfter)),
+				fmt.Sprintf("Last status %s", strings.ToLower(string(jobData.OverallStatus))),
+			},
+		})
+	}
+	return records
+}
+
+// testgrid reports run timestamps in milliseconds and update timestamps in seconds
+func testgridTimestamp(ts int64) time.Time {
+	if ts > 1e12 {
+		return time.Unix(0, ts*int64(time.Millisecond))
+	}
+	return time.Unix(ts, 0)
+}
+
 // Parses string with the given regular expression and returns the group values defined in the expression.
 // e.g. `(?P<Year>\d{4})-(?P<Month>\d{2})-(?P<Day>\d{2})` + `2015-05-27` -> map[Year:2015 Month:05 Day:27]
 func getRegexParams(regEx, s string) (paramsMap map[string]string) {
@@ -269,8 +414,16 @@ type testgridJob struct {
 	OutputName string
 	URLName    string
 	Emoji      string
+	// StaleAfter expected cadence, jobs that have not been run for longer are reported as stale
+	StaleAfter time.Duration
 }
 
+// Default expected cadence of the dashboards
+const (
+	blockingStaleAfter  = 24 * time.Hour
+	informingStaleAfter = 72 * time.Hour
+)
+
 // The types below reflect testgrid summary json (e.g. https://testgrid.k8s.io/sig-release-master-informing/summary)
 
 // TestgridData contains all jobs under one specific field like 'sig-release-master-informing'
@@ -343,4 +496,5 @@ const (
 const (
 	testgridReportSummary = 0
 	testgridReportDetails = 1
+	testgridReportStale   = 2
 )
@@ -0,0 +1,146 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testgridTestURL = "https://testgrid.k8s.io/sig-release-master-blocking"
+
+// testgridNow fixed time the testgrid jobs are evaluated at
+var testgridNow = time.Date(2021, time.October, 1, 12, 0, 0, 0, time.UTC)
+
+// testgridRunAt returns the testgrid run timestamp (milliseconds) of a run that happened ago before testgridNow
+func testgridRunAt(ago time.Duration) int64 {
+	return testgridNow.Add(-ago).UnixNano() / int64(time.Millisecond)
+}
+
+func TestGetStaleJobs(t *testing.T) {
+	jobs := TestgridData{
+		// jobs that never ran are reported as stale by testgrid already
+		"ci-never-ran": {OverallStatus: stale},
+		"ci-recent":    {OverallStatus: passing, LastRunTimestamp: testgridRunAt(time.Hour)},
+		"ci-exactly":   {OverallStatus: passing, LastRunTimestamp: testgridRunAt(24 * time.Hour)},
+		"ci-older": {OverallStatus: flaky, LastRunTimestamp: testgridRunAt(50 * time.Hour), Tests: []test{
+			{TestName: "Kubernetes e2e suite.[sig-node] Pods should be updated"},
+		}},
+		// update timestamps are seconds
+		"ci-older-seconds": {OverallStatus: passing, LastRunTimestamp: testgridNow.Add(-25 * time.Hour).Unix()},
+	}
+	got := getStaleJobs(jobs, testgridTestURL, 24*time.Hour, MediumSeverity, testgridNow)
+	sortRecords(got, sortByName)
+
+	want := []ReportDataRecord{
+		{
+			ID:        testgridReportStale,
+			URL:       testgridTestURL + "#ci-older",
+			Title:     "ci-older",
+			Sig:       "[sig/node]",
+			Sigs:      []string{"node"},
+			Status:    string(stale),
+			Severity:  MediumSeverity,
+			Highlight: statusOldEmoji + statusOldEmoji,
+			Notes:     []string{"Last run 2021-09-29 10:00 UTC (2d 2h ago, expected every 1d 0h)", "Last status flaky"},
+		},
+		{
+			ID:        testgridReportStale,
+			URL:       testgridTestURL + "#ci-older-seconds",
+			Title:     "ci-older-seconds",
+			Sig:       "[]",
+			Sigs:      []string{},
+			Status:    string(stale),
+			Severity:  MediumSeverity,
+			Highlight: statusOldEmoji + statusOldEmoji,
+			Notes:     []string{"Last run 2021-09-30 11:00 UTC (1d 1h ago, expected every 1d 0h)", "Last status passing"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("getStaleJobs() = %+v, want %+v", got, want)
+	}
+}
+
+func TestGetDashboardRecordsStaleSeverity(t *testing.T) {
+	jobs := TestgridData{
+		"ci-passing": {OverallStatus: passing, LastRunTimestamp: testgridRunAt(time.Hour)},
+		"ci-flaky":   {OverallStatus: flaky, LastRunTimestamp: testgridRunAt(time.Hour), Status: "9 of 10 (90.0%) recent columns passed"},
+		"ci-stale":   {OverallStatus: passing, LastRunTimestamp: testgridRunAt(48 * time.Hour)},
+	}
+	job := testgridJob{OutputName: "Master-Blocking", StaleAfter: 24 * time.Hour}
+	for _, shortOn := range []bool{false, true} {
+		meta := Meta{Flags: metaFlags{ShortOn: shortOn, StaleSeverity: HighSeverity, SortBy: sortByName}}
+		records := getDashboardRecords(meta, job, jobs, testgridTestURL, testgridNow)
+		summary := records[0]
+		// the flaky job is light, the stale job raises the severity of the dashboard
+		if summary.Severity != HighSeverity {
+			t.Errorf("-short %v: summary severity = %d, want %d", shortOn, summary.Severity, HighSeverity)
+		}
+		if note := summary.Notes[len(summary.Notes)-1]; note != "1 jobs not run in 1d 0h" {
+			t.Errorf("-short %v: last summary note = %q, want the stale jobs", shortOn, note)
+		}
+		wantRecords := 3
+		if shortOn {
+			wantRecords = 1
+		}
+		if len(records) != wantRecords {
+			t.Errorf("-short %v: got %d records, want %d", shortOn, len(records), wantRecords)
+		}
+	}
+}
+
+func TestGetFailureStreak(t *testing.T) {
+	since := func(ago time.Duration) *time.Time {
+		t := time.Unix(0, testgridRunAt(ago)*int64(time.Millisecond))
+		return &t
+	}
+	tests := []struct {
+		name       string
+		tests      []test
+		wantSince  *time.Time
+		wantStreak int
+	}{
+		{name: "no runs", tests: nil},
+		{name: "no failures", tests: []test{{TestName: "Overall", PassTimestamp: testgridRunAt(time.Hour)}}},
+		// the test failed in every column testgrid has, there is no passing run
+		{name: "streak runs to the end of the data", tests: []test{
+			{TestName: "Overall", FailCount: 10, FailTimestamp: testgridRunAt(30 * time.Hour)},
+		}, wantSince: since(30 * time.Hour), wantStreak: 10},
+		// the test passed after failing before, only the failures since the last pass count
+		{name: "interrupted streak", tests: []test{
+			{TestName: "Overall", FailCount: 3, FailTimestamp: testgridRunAt(6 * time.Hour), PassTimestamp: testgridRunAt(8 * time.Hour)},
+		}, wantSince: since(6 * time.Hour), wantStreak: 3},
+		// the earliest first failure and the longest streak of the failing tests
+		{name: "several failing tests", tests: []test{
+			{TestName: "Kubernetes e2e suite.[sig-node] Pods", FailCount: 2, FailTimestamp: testgridRunAt(2 * time.Hour)},
+			{TestName: "Overall", FailCount: 5, FailTimestamp: testgridRunAt(10 * time.Hour), PassTimestamp: testgridRunAt(11 * time.Hour)},
+			{TestName: "Kubernetes e2e suite.[sig-storage] CSI", FailCount: 4},
+		}, wantSince: since(10 * time.Hour), wantStreak: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotSince, gotStreak := getFailureStreak(tt.tests)
+			if (gotSince == nil) != (tt.wantSince == nil) || (gotSince != nil && !gotSince.Equal(*tt.wantSince)) {
+				t.Errorf("getFailureStreak() since = %v, want %v", gotSince, tt.wantSince)
+			}
+			if gotStreak != tt.wantStreak {
+				t.Errorf("getFailureStreak() streak = %d, want %d", gotStreak, tt.wantStreak)
+			}
+		})
+	}
+}
@@ -17,10 +17,12 @@ limitations under the License.
 package cireporter
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"sync"
+	"time"
 )
 
 // Reports
@@ -41,6 +43,8 @@ const (
 	statusFlakyEmoji     = "\U0001F535"
 	statusNewEmoji       = "\U00002728"
 	statusOldEmoji       = "\U0001F319"
+	severityMediumEmoji  = "\U0001F7E0"
+	severityLightEmoji   = "\U0001F7E1"
 )
 
 const (
@@ -66,7 +70,7 @@ const (
 
 // CIReport this interface to implement Reporters
 type CIReport interface {
-	RequestData(meta Meta, wg *sync.WaitGroup) ReportData
+	RequestData(ctx context.Context, meta Meta, wg *sync.WaitGroup) ReportData
 	Print(meta Meta, reportData ReportData)
 	PutData(reportData ReportData)
 	GetData() ReportData
@@ -93,7 +97,8 @@ func (r *Report) PrintJSON() {
 	fmt.Print(string(b))
 }
 
-// Report wraps multiple report data objects
+// Report wraps multiple report data objects. Report, ReportData, ReportDataField and ReportDataRecord are the
+// contract of the -template and -json output: fields are only added, never renamed or removed.
 type Report []ReportData
 
 // ReportData that contains multiple data fields
@@ -108,6 +113,10 @@ type ReportDataField struct {
 	Emoji   string             `json:"emoji"`
 	Title   string             `json:"title"`
 	Records []ReportDataRecord `json:"records"`
+	// Error is set if the data could not be requested completely (records may be missing)
+	Error string `json:"error,omitempty"`
+	// FilteredOut number of github issues each filter rule removed from the records
+	FilteredOut map[string]int `json:"filtered_out,omitempty"`
 }
 
 // ReportDataRecord that contain specifc information about a testgrid job or about a github issue (flexible)
@@ -120,6 +129,8 @@ type ReportDataRecord struct {
 	Title string `json:"title"`
 	// k8s sig reference
 	Sig string `json:"sig"`
+	// normalized names of the k8s sigs involved (e.g. 'storage')
+	Sigs []string `json:"sigs,omitempty"`
 	// collection of additional information
 	Notes []string `json:"notes"`
 	// record status
@@ -128,4 +139,68 @@ type ReportDataRecord struct {
 	Severity Severity `json:"severity"`
 	// can be set to highlight the record (with an emoji for example)
 	Highlight string `json:"highlight"`
+	// last green run of a failing testgrid job
+	LastGreen string `json:"last_green,omitempty"`
+	// start of the current failure streak of a testgrid job
+	FailingSince *time.Time `json:"failing_since,omitempty"`
+	// number of consecutive failing runs of a testgrid job
+	FailureStreak int `json:"failure_streak,omitempty"`
+	// creation time of a github issue
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	// last update of a github issue
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	// names of the failing tests of a testgrid job
+	FailingTests []string `json:"failing_tests,omitempty"`
+	// labels of a github issue
+	Labels []string `json:"labels,omitempty"`
+	// number of testgrid jobs by status (lowercase, e.g. 'failing') of a dashboard summary
+	Counts map[string]int `json:"counts,omitempty"`
+	// names of the passing, failing and flaky jobs of a testgrid dashboard summary (of all sigs, also with -short)
+	PassingJobs []string `json:"passing_jobs,omitempty"`
+	FailingJobs []string `json:"failing_jobs,omitempty"`
+	FlakyJobs   []string `json:"flaky_jobs,omitempty"`
+	// number of passed runs of the recent runs of a testgrid job
+	RecentPasses int `json:"recent_passes,omitempty"`
+	// number of recent runs of a testgrid job
+	RecentRuns int `json:"recent_runs,omitempty"`
+	// repository of a github issue (e.g. 'kubernetes/kubernetes')
+	Repo string `json:"repo,omitempty"`
+	// milestone of a github issue
+	Milestone string `json:"milestone,omitempty"`
+	// number of comments of a github issue
+	Comments int64 `json:"comments,omitempty"`
+	// age of a github issue like '1-4 weeks'
+	AgeBucket string `json:"age_bucket,omitempty"`
+	// days since the last update of a github issue
+	InactiveDays int `json:"inactive_days,omitempty"`
+	// author of the last comment of a github issue
+	LastCommentBy string `json:"last_comment_by,omitempty"`
+	// set if the last comment of a github issue has been written by a bot
+	LastCommentByBot bool `json:"last_comment_by_bot,omitempty"`
+	// logins of the assignees of a github issue
+	Assignees []string `json:"assignees,omitempty"`
+	// login of the author of a github issue
+	Author string `json:"author,omitempty"`
+	// pull requests that reference a github issue
+	PullRequests []LinkedPullRequest `json:"pull_requests,omitempty"`
+	// columns of the projects a github issue is in (like 'In Progress')
+	ProjectColumns []string `json:"project_columns,omitempty"`
+	// labels of the queries that found a github issue (like 'kind/flake')
+	MatchedBy []string `json:"matched_by,omitempty"`
+	// reasons why a github issue needs attention (like 'no update for 20 days')
+	Attention []string `json:"attention,omitempty"`
+}
+
+// humanDuration formats a duration in days, hours and minutes (e.g. "6d 3h" or "2h 10m")
+func humanDuration(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	minutes := int(d.Minutes()) % 60
+	if days > 0 {
+		return fmt.Sprintf("%dd %dh", days, hours)
+	}
+	return fmt.Sprintf("%dh %dm", hours, minutes)
 }
@@ -0,0 +1,197 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// ReportChanges payload that is sent to the change hooks
+type ReportChanges struct {
+	DetectedAt time.Time      `json:"detected_at"`
+	Changes    []ReportChange `json:"changes"`
+}
+
+// Watch requests the report in the interval set via -watch until ctx is done. The first report is printed
+// completely, afterwards only the changes since the last iteration are printed and sent to the change hooks. An
+// error is returned if the first report can not be printed.
+func Watch(ctx context.Context, meta Meta) error {
+	var previous Report
+	for {
+		cireporters := meta.GetReporters()
+		report := RequestReport(ctx, meta, cireporters)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if previous == nil {
+			if err := PrintReport(meta, cireporters, report); err != nil {
+				return err
+			}
+		} else {
+			changes := ReportChanges{DetectedAt: time.Now(), Changes: DiffReports(previous, report)}
+			printChanges(meta, changes)
+			if len(changes.Changes) != 0 {
+				notifyChanges(ctx, meta, changes)
+			}
+		}
+		previous = mergeCompleteReportData(previous, report)
+
+		select {
+		case <-time.After(meta.Flags.Watch):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// mergeCompleteReportData replaces the report data of previous with the data of current. Scopes of current that
+// are incomplete (a dashboard or the github issues, see diffScope) keep the data of previous, so changes are
+// detected against the last complete data of every scope and the changes of the complete scopes are not reported
+// twice.
+func mergeCompleteReportData(previous Report, current Report) Report {
+	merged := Report{}
+	for _, reportData := range current {
+		incomplete := map[string]bool{}
+		for _, field := range reportData.Data {
+			if field.Error != "" {
+				incomplete[diffScope(reportData.Name, field.Title)] = true
+			}
+		}
+		prev, ok := findReportData(previous, reportData.Name)
+		if len(incomplete) == 0 || !ok {
+			merged = append(merged, reportData)
+			continue
+		}
+		prevFields := map[string][]ReportDataField{}
+		for _, field := range prev.Data {
+			scope := diffScope(prev.Name, field.Title)
+			prevFields[scope] = append(prevFields[scope], field)
+		}
+		mergedData := reportData
+		mergedData.Data = []ReportDataField{}
+		replaced := map[string]bool{}
+		for _, field := range reportData.Data {
+			scope := diffScope(reportData.Name, field.Title)
+			if !incomplete[scope] || len(prevFields[scope]) == 0 {
+				mergedData.Data = append(mergedData.Data, field)
+				continue
+			}
+			if !replaced[scope] {
+				mergedData.Data = append(mergedData.Data, prevFields[scope]...)
+				replaced[scope] = true
+			}
+		}
+		merged = append(merged, mergedData)
+	}
+	return merged
+}
+
+// findReportData returns the report data with the given name
+func findReportData(report Report, name string) (ReportData, bool) {
+	for _, reportData := range report {
+		if reportData.Name == name {
+			return reportData, true
+		}
+	}
+	return ReportData{}, false
+}
+
+func printChanges(meta Meta, changes ReportChanges) {
+	if meta.Flags.JSONOut {
+		b, err := json.Marshal(changes)
+		if err != nil {
+			log.Printf("Could not marshal changes %v", err)
+			return
+		}
+		fmt.Println(string(b))
+		return
+	}
+	timestamp := changes.DetectedAt.Format("2006-01-02 15:04:05")
+	if len(changes.Changes) == 0 {
+		fmt.Printf("[%s] no changes\n", timestamp)
+		return
+	}
+	for _, change := range changes.Changes {
+		emoji := ""
+		if !meta.Flags.EmojisOff {
+			switch change.Kind {
+			case JobFailing:
+				emoji = statusFailingEmoji + " "
+			case JobFlaky:
+				emoji = statusFlakyEmoji + " "
+			case JobRecovered, IssueClosed:
+				emoji = resolvedEmoji + " "
+			case IssueNew:
+				emoji = statusNewEmoji + " "
+			}
+		}
+		fmt.Printf("[%s] %s%s\n", timestamp, emoji, change)
+	}
+}
+
+// notifyChanges runs the command set via -on-change-exec and posts to the webhook set via -on-change-webhook,
+// both receive the changes as json. Errors are logged and do not stop watching.
+func notifyChanges(ctx context.Context, meta Meta, changes ReportChanges) {
+	payload, err := json.Marshal(changes)
+	if err != nil {
+		log.Printf("Could not marshal changes %v", err)
+		return
+	}
+	if meta.Flags.OnChangeExec != "" {
+		cmd := exec.CommandContext(ctx, "sh", "-c", meta.Flags.OnChangeExec)
+		cmd.Stdin = bytes.NewReader(payload)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		cmd.Env = append(os.Environ(), fmt.Sprintf("CI_SIGNAL_CHANGES=%d", len(changes.Changes)))
+		if err := cmd.Run(); err != nil {
+			log.Printf("Error running -on-change-exec: %v", err)
+		}
+	}
+	if meta.Flags.OnChangeWebhook != "" {
+		client := &http.Client{Timeout: meta.Flags.RequestTimeout}
+		if err := postWebhook(ctx, client, meta.Flags.OnChangeWebhook, payload); err != nil {
+			log.Printf("Error posting to -on-change-webhook: %v", err)
+		}
+	}
+}
+
+// postWebhook posts the changes once, it does not go through the Fetcher as a retried post could deliver the same
+// changes several times
+func postWebhook(ctx context.Context, client *http.Client, url string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return nil
+}
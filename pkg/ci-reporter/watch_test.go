@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMergeCompleteReportData(t *testing.T) {
+	previous := loadReportFixture(t, "previous.json")
+	incomplete := loadReportFixture(t, "current-incomplete.json")
+	current := loadReportFixture(t, "current.json")
+
+	// the dashboard that loaded reports its change once
+	first := changeSummary(DiffReports(previous, incomplete))
+	if want := []string{"job_flaky Master-Informing gce-master-alpha"}; !reflect.DeepEqual(first, want) {
+		t.Fatalf("first iteration = %q, want %q", first, want)
+	}
+	merged := mergeCompleteReportData(previous, incomplete)
+
+	// the incomplete scopes keep the previous data, the complete ones the current data
+	for _, reportData := range merged {
+		for _, field := range reportData.Data {
+			if field.Error != "" {
+				t.Errorf("merged %s %s is incomplete", reportData.Name, field.Title)
+			}
+		}
+	}
+
+	second := changeSummary(DiffReports(merged, current))
+	want := []string{
+		"job_failing Master-Blocking kind-master-parallel",
+		"job_recovered Master-Blocking gce-cos-master-default",
+		"issue_closed  [Failing test] gci-gce-serial",
+		"issue_new  Failure test: Volume metrics Ephemeral",
+	}
+	if !reflect.DeepEqual(second, want) {
+		t.Errorf("second iteration = %q, want %q", second, want)
+	}
+}
+
+func TestMergeCompleteReportDataWithoutPrevious(t *testing.T) {
+	incomplete := loadReportFixture(t, "current-incomplete.json")
+	merged := mergeCompleteReportData(nil, incomplete)
+	if !reflect.DeepEqual(merged, incomplete) {
+		t.Errorf("mergeCompleteReportData(nil, current) = %+v, want %+v", merged, incomplete)
+	}
+}
+
+func TestPostWebhook(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "delivered", status: http.StatusNoContent},
+		// the changes are not posted again, the receiver could have processed them already
+		{name: "server error", status: http.StatusServiceUnavailable, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int64
+			var body, contentType string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt64(&requests, 1)
+				b, _ := ioutil.ReadAll(r.Body)
+				body, contentType = string(b), r.Header.Get("Content-Type")
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			err := postWebhook(context.Background(), server.Client(), server.URL, []byte(`{"changes":[]}`))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("postWebhook() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := atomic.LoadInt64(&requests); got != 1 {
+				t.Errorf("got %d requests, want 1", got)
+			}
+			if body != `{"changes":[]}` || contentType != "application/json" {
+				t.Errorf("got body %q with content type %q", body, contentType)
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// weeklyTopFailingJobs maximum number of failing blocking jobs listed in the weekly summary
+	weeklyTopFailingJobs = 10
+)
+
+// Health of a testgrid dashboard in the weekly summary
+const (
+	healthFailing = "failing"
+	healthFlaky   = "flaky"
+	healthPassing = "passing"
+	healthUnknown = "unknown"
+)
+
+// WeeklySummary data the weekly template is rendered with
+type WeeklySummary struct {
+	GeneratedAt time.Time
+	// PreviousAt time of the snapshot the report is compared to, nil if there is no snapshot yet
+	PreviousAt *time.Time
+	Dashboards []WeeklyDashboard
+	// TopFailingJobs failing jobs of the blocking dashboards, highest severity first
+	TopFailingJobs []WeeklyJob
+	// JobChanges testgrid jobs that went red, started flaking or recovered since the snapshot
+	JobChanges   []ReportChange
+	NewIssues    []ReportChange
+	ClosedIssues []ReportChange
+	// NeedsAttention open issues without sig or without recent human activity (see -attention-days)
+	NeedsAttention []WeeklyIssue
+	// Errors of the report data that could not be requested completely
+	Errors []string
+	// Report the complete current report
+	Report Report
+}
+
+// WeeklyDashboard overall health of a testgrid dashboard
+type WeeklyDashboard struct {
+	Name     string
+	Blocking bool
+	// Health options: 'failing', 'flaky', 'passing', 'unknown' (could not be requested)
+	Health string
+	// Counts number of jobs by lowercase status like 'failing' or 'total'
+	Counts map[string]int
+	Error  string
+}
+
+// WeeklyJob a failing testgrid job and the dashboard it belongs to
+type WeeklyJob struct {
+	Dashboard string
+	Record    ReportDataRecord
+}
+
+// WeeklyIssue an issue that needs attention and why
+type WeeklyIssue struct {
+	Reason string
+	Record ReportDataRecord
+}
+
+// weeklySnapshot the report the next weekly summary is compared to
+type weeklySnapshot struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	Report      Report    `json:"report"`
+}
+
+// defaultWeeklySnapshot returns the snapshot path if -snapshot is not set
+func defaultWeeklySnapshot() string {
+	return filepath.Join(defaultCacheDir(), "weekly-snapshot.json")
+}
+
+// NewWeeklySummary summarizes the current report and its changes since the previous one (previous can be nil)
+func NewWeeklySummary(current Report, generatedAt time.Time, previous Report, previousAt *time.Time) WeeklySummary {
+	summary := WeeklySummary{GeneratedAt: generatedAt, PreviousAt: previousAt, Report: current}
+	for _, reportData := range current {
+		for _, field := range reportData.Data {
+			if field.Error != "" {
+				summary.Errors = append(summary.Errors, fmt.Sprintf("%s %s: %s", reportData.Name, field.Title, field.Error))
+			}
+			switch reportData.Name {
+			case testgridReport:
+				summary.Dashboards = append(summary.Dashboards, weeklyDashboard(field))
+				if !isBlockingDashboard(field.Title) {
+					continue
+				}
+				for _, record := range field.Records {
+					if record.ID == testgridReportDetails && record.Status == string(failing) {
+						summary.TopFailingJobs = append(summary.TopFailingJobs, WeeklyJob{Dashboard: field.Title, Record: record})
+					}
+				}
+			case githubReport:
+				for _, record := range field.Records {
+					if len(record.Attention) != 0 {
+						summary.NeedsAttention = append(summary.NeedsAttention, WeeklyIssue{Reason: strings.Join(record.Attention, ", "), Record: record})
+					}
+				}
+			}
+		}
+	}
+
+	// The jobs of every dashboard are already sorted, the top jobs are taken across all blocking dashboards
+	records := make([]ReportDataRecord, len(summary.TopFailingJobs))
+	dashboards := map[string]string{}
+	for i, job := range summary.TopFailingJobs {
+		records[i] = job.Record
+		dashboards[job.Record.URL] = job.Dashboard
+	}
+	sortRecords(records, sortBySeverity)
+	summary.TopFailingJobs = nil
+	for i, record := range records {
+		if i == weeklyTopFailingJobs {
+			break
+		}
+		summary.TopFailingJobs = append(summary.TopFailingJobs, WeeklyJob{Dashboard: dashboards[record.URL], Record: record})
+	}
+
+	if previous != nil {
+		for _, change := range DiffReports(previous, current) {
+			switch change.Kind {
+			case IssueNew:
+				summary.NewIssues = append(summary.NewIssues, change)
+			case IssueClosed:
+				summary.ClosedIssues = append(summary.ClosedIssues, change)
+			default:
+				summary.JobChanges = append(summary.JobChanges, change)
+			}
+		}
+	}
+	return summary
+}
+
+// weeklyDashboard returns the overall health of a testgrid dashboard
+func weeklyDashboard(field ReportDataField) WeeklyDashboard {
+	dashboard := WeeklyDashboard{Name: field.Title, Blocking: isBlockingDashboard(field.Title), Health: healthUnknown, Counts: map[string]int{}, Error: field.Error}
+	for _, record := range field.Records {
+		if record.ID != testgridReportSummary {
+			continue
+		}
+		dashboard.Counts = record.Counts
+		if record.Counts[strings.ToLower(string(failing))] != 0 {
+			dashboard.Health = healthFailing
+		} else if record.Counts[strings.ToLower(string(flaky))] != 0 {
+			dashboard.Health = healthFlaky
+		} else {
+			dashboard.Health = healthPassing
+		}
+	}
+	return dashboard
+}
+
+// isBlockingDashboard checks if a dashboard title refers to a release blocking dashboard like 'Master-Blocking'
+func isBlockingDashboard(title string) bool {
+	return strings.HasSuffix(strings.ToLower(title), "blocking")
+}
+
+// WriteWeeklySummary renders the weekly summary of the report with the default template (or the one set via
+// -template) and stores the report as snapshot for the next week
+func WriteWeeklySummary(w io.Writer, meta Meta, current Report, generatedAt time.Time) error {
+	tmpl, err := weeklyTemplate(meta.Flags.Template)
+	if err != nil {
+		return err
+	}
+	snapshot, err := loadWeeklySnapshot(meta.Flags.Snapshot)
+	if err != nil {
+		return err
+	}
+	var previous Report
+	var previousAt *time.Time
+	if snapshot != nil {
+		previous = snapshot.Report
+		previousAt = &snapshot.GeneratedAt
+	}
+	if err := tmpl.Execute(w, NewWeeklySummary(current, generatedAt, previous, previousAt)); err != nil {
+		return err
+	}
+	if !meta.Flags.UpdateSnapshot {
+		return nil
+	}
+	// data that could not be requested is taken from the old snapshot to not report it as changed next week
+	return saveWeeklySnapshot(meta.Flags.Snapshot, weeklySnapshot{GeneratedAt: generatedAt, Report: mergeCompleteReportData(previous, current)})
+}
+
+// weeklyTemplate parses the template file, the default template is used if path is empty
+func weeklyTemplate(path string) (executableTemplate, error) {
+	if path == "" {
+		return template.New("weekly").Funcs(templateFuncs).Parse(defaultWeeklyTemplate)
+	}
+	return parseTemplateFile(path)
+}
+
+// loadWeeklySnapshot returns nil if no snapshot has been stored yet
+func loadWeeklySnapshot(path string) (*weeklySnapshot, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snapshot weeklySnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("snapshot %s is invalid: %v", path, err)
+	}
+	return &snapshot, nil
+}
+
+func saveWeeklySnapshot(path string, snapshot weeklySnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// defaultWeeklyTemplate markdown template of the weekly summary
+const defaultWeeklyTemplate = `# CI signal weekly update {{ .GeneratedAt.Format "2006-01-02" }}
+{{ if .PreviousAt }}
+Changes since {{ .PreviousAt.Format "2006-01-02" }}.
+{{ else }}
+There is no previous snapshot yet, changes are reported from the next update on.
+{{ end }}
+## Overall health
+{{ range .Dashboards }}
+- **{{ .Name }}**: {{ .Health }}{{ if .Error }} (could not be requested: {{ .Error }}){{ else }} ({{ count .Counts "failing" }} failing, {{ count .Counts "flaky" }} flaky of {{ count .Counts "total" }} jobs){{ end }}
+{{- end }}
+
+## Top failing blocking jobs
+{{ range .TopFailingJobs }}
+- [{{ .Record.Title }}]({{ .Record.URL }}) on {{ .Dashboard }}{{ with .Record.FailingSince }}, failing for {{ since . }}{{ end }}{{ with .Record.Sig }} {{ . }}{{ end }}
+{{- else }}
+- none
+{{- end }}
+{{ if .PreviousAt }}
+## Job changes
+{{ range .JobChanges }}
+- {{ . }}
+{{- else }}
+- none
+{{- end }}
+
+## New issues
+{{ range .NewIssues }}
+- [{{ .IssueReference }} {{ .Title }}]({{ .URL }})
+{{- else }}
+- none
+{{- end }}
+
+## Closed issues
+{{ range .ClosedIssues }}
+- [{{ .IssueReference }} {{ .Title }}]({{ .URL }})
+{{- else }}
+- none
+{{- end }}
+{{ end }}
+## Needs owner attention
+{{ range .NeedsAttention }}
+- [{{ issueReference .Record }} {{ .Record.Title }}]({{ .Record.URL }}) {{ .Reason }}{{ with .Record.Sig }} {{ . }}{{ end }}
+{{- else }}
+- none
+{{- end }}
+{{ if .Errors }}
+> The report is incomplete: {{ join .Errors "; " }}
+{{ end }}`
@@ -0,0 +1,165 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cireporter
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// weeklyReport current report of testdata/diff with an issue that needs attention
+func weeklyReport(t *testing.T) Report {
+	t.Helper()
+	report := loadReportFixture(t, "current.json")
+	issue := &report[1].Data[0].Records[1]
+	issue.Attention = []string{"no update for 21 days", noSigAttention, noAssigneeAttention}
+	return report
+}
+
+// loadWeeklyGolden reads the expected weekly summary of testdata/weekly
+func loadWeeklyGolden(t *testing.T, name string) string {
+	t.Helper()
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "weekly", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestWriteWeeklySummary(t *testing.T) {
+	snapshot := filepath.Join(t.TempDir(), "weekly", "snapshot.json")
+	meta := Meta{Command: WeeklyCommand, Flags: metaFlags{Snapshot: snapshot, UpdateSnapshot: true}}
+	firstWeek := time.Date(2021, time.October, 25, 9, 0, 0, 0, time.UTC)
+	secondWeek := firstWeek.AddDate(0, 0, 7)
+
+	// the first update has nothing to be compared to and stores the snapshot
+	var b bytes.Buffer
+	if err := WriteWeeklySummary(&b, meta, loadReportFixture(t, "previous.json"), firstWeek); err != nil {
+		t.Fatalf("WriteWeeklySummary() error = %v", err)
+	}
+	if want := loadWeeklyGolden(t, "first-update.md"); b.String() != want {
+		t.Errorf("WriteWeeklySummary() first update =\n%s\nwant:\n%s", b.String(), want)
+	}
+	stored, err := loadWeeklySnapshot(snapshot)
+	if err != nil || stored == nil || !stored.GeneratedAt.Equal(firstWeek) {
+		t.Fatalf("loadWeeklySnapshot() = %v, %v, want the snapshot of %s", stored, err, firstWeek)
+	}
+
+	// the next update reports the changes, the snapshot is kept with -update-snapshot=false
+	meta.Flags.UpdateSnapshot = false
+	b.Reset()
+	if err := WriteWeeklySummary(&b, meta, weeklyReport(t), secondWeek); err != nil {
+		t.Fatalf("WriteWeeklySummary() error = %v", err)
+	}
+	if want := loadWeeklyGolden(t, "changes.md"); b.String() != want {
+		t.Errorf("WriteWeeklySummary() changes =\n%s\nwant:\n%s", b.String(), want)
+	}
+	if stored, err := loadWeeklySnapshot(snapshot); err != nil || !stored.GeneratedAt.Equal(firstWeek) {
+		t.Errorf("snapshot has been replaced with -update-snapshot=false: %v, %v", stored, err)
+	}
+}
+
+func TestWriteWeeklySummaryInvalidSnapshot(t *testing.T) {
+	snapshot := filepath.Join(t.TempDir(), "snapshot.json")
+	if err := ioutil.WriteFile(snapshot, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	meta := Meta{Command: WeeklyCommand, Flags: metaFlags{Snapshot: snapshot, UpdateSnapshot: true}}
+	if err := WriteWeeklySummary(ioutil.Discard, meta, weeklyReport(t), time.Now()); err == nil {
+		t.Error("WriteWeeklySummary() with an invalid snapshot returned no error")
+	}
+	if b, _ := ioutil.ReadFile(snapshot); string(b) != "{" {
+		t.Errorf("invalid snapshot has been replaced with %s", b)
+	}
+}
+
+func TestNewWeeklySummary(t *testing.T) {
+	failingSince := time.Date(2021, time.October, 30, 0, 0, 0, 0, time.UTC)
+	current := Report{
+		{Name: testgridReport, Data: []ReportDataField{
+			{Title: "Master-Blocking", Records: []ReportDataRecord{
+				{ID: testgridReportSummary, Counts: map[string]int{"total": 3, "failing": 2}},
+				{ID: testgridReportDetails, Title: "light", URL: "light", Status: string(failing), Severity: LightSeverity},
+				{ID: testgridReportDetails, Title: "high", URL: "high", Status: string(failing), Severity: HighSeverity, FailingSince: &failingSince},
+				{ID: testgridReportDetails, Title: "flaky", URL: "flaky", Status: string(flaky), Severity: HighSeverity},
+			}},
+			{Title: "Master-Informing", Records: []ReportDataRecord{
+				{ID: testgridReportSummary, Counts: map[string]int{"total": 2, "flaky": 1}},
+				{ID: testgridReportDetails, Title: "informing", URL: "informing", Status: string(failing), Severity: HighSeverity},
+			}},
+			{Title: "1.22-Blocking", Records: []ReportDataRecord{}, Error: "503 Service Unavailable"},
+		}},
+		{Name: githubReport, Data: []ReportDataField{{Records: []ReportDataRecord{
+			{ID: 1, Title: "recent"},
+			{ID: 2, Title: "inactive", Attention: []string{"no update for 30 days", noAssigneeAttention}},
+		}}}},
+	}
+	summary := NewWeeklySummary(current, time.Now(), nil, nil)
+
+	dashboards := map[string]string{}
+	for _, dashboard := range summary.Dashboards {
+		dashboards[dashboard.Name] = dashboard.Health
+	}
+	if want := map[string]string{"Master-Blocking": healthFailing, "Master-Informing": healthFlaky, "1.22-Blocking": healthUnknown}; !reflect.DeepEqual(dashboards, want) {
+		t.Errorf("dashboard health = %v, want %v", dashboards, want)
+	}
+	// informing and flaky jobs are no top failing jobs
+	topJobs := []string{}
+	for _, job := range summary.TopFailingJobs {
+		topJobs = append(topJobs, job.Dashboard+" "+job.Record.Title)
+	}
+	if want := []string{"Master-Blocking high", "Master-Blocking light"}; !reflect.DeepEqual(topJobs, want) {
+		t.Errorf("TopFailingJobs = %v, want %v", topJobs, want)
+	}
+	if len(summary.NeedsAttention) != 1 || summary.NeedsAttention[0].Reason != "no update for 30 days, no assignee" {
+		t.Errorf("NeedsAttention = %+v, want issue 2", summary.NeedsAttention)
+	}
+	if want := []string{"testgrid 1.22-Blocking: 503 Service Unavailable"}; !reflect.DeepEqual(summary.Errors, want) {
+		t.Errorf("Errors = %v, want %v", summary.Errors, want)
+	}
+	// without a snapshot no changes are reported
+	if summary.JobChanges != nil || summary.NewIssues != nil || summary.ClosedIssues != nil {
+		t.Errorf("changes reported without a snapshot: %v %v %v", summary.JobChanges, summary.NewIssues, summary.ClosedIssues)
+	}
+}
+
+func TestNewWeeklySummaryTopFailingJobsLimit(t *testing.T) {
+	records := []ReportDataRecord{{ID: testgridReportSummary}}
+	for i := 0; i < weeklyTopFailingJobs+5; i++ {
+		records = append(records, ReportDataRecord{ID: testgridReportDetails, Title: string(rune('a' + i)), URL: string(rune('a' + i)), Status: string(failing)})
+	}
+	summary := NewWeeklySummary(Report{{Name: testgridReport, Data: []ReportDataField{{Title: "Master-Blocking", Records: records}}}}, time.Now(), nil, nil)
+	if len(summary.TopFailingJobs) != weeklyTopFailingJobs {
+		t.Errorf("got %d top failing jobs, want %d", len(summary.TopFailingJobs), weeklyTopFailingJobs)
+	}
+}
+
+func TestLoadWeeklySnapshotMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.json")
+	snapshot, err := loadWeeklySnapshot(path)
+	if snapshot != nil || err != nil {
+		t.Errorf("loadWeeklySnapshot() = %v, %v, want nil, nil", snapshot, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("loadWeeklySnapshot() created the snapshot")
+	}
+}